@@ -1,6 +1,8 @@
 package helm2
 
 import (
+	"strings"
+
 	"get.porter.sh/porter/pkg/exec/builder"
 )
 
@@ -64,10 +66,12 @@ type ExecuteStep struct {
 }
 
 type ExecuteInstruction struct {
-	Step      `yaml:",inline"`
-	Namespace string        `yaml:"namespace,omitempty"`
-	Arguments []string      `yaml:"arguments,omitempty"`
-	Flags     builder.Flags `yaml:"flags,omitempty"`
+	Step               `yaml:",inline"`
+	KubernetesSettings `yaml:",inline"`
+	TillerSettings     `yaml:",inline"`
+	Namespace          string        `yaml:"namespace,omitempty"`
+	Arguments          []string      `yaml:"arguments,omitempty"`
+	Flags              builder.Flags `yaml:"flags,omitempty"`
 }
 
 func (s ExecuteStep) GetCommand() string {
@@ -79,5 +83,51 @@ func (s ExecuteStep) GetArguments() []string {
 }
 
 func (s ExecuteStep) GetFlags() builder.Flags {
-	return s.Flags
+	flags := make(builder.Flags, len(s.Flags))
+	copy(flags, s.Flags)
+
+	// Point helm at the same cluster as the mixin's kubernetes client
+	if s.InCluster {
+		flags = append(flags, builder.NewFlag("kubeconfig", inClusterKubeConfig))
+	} else if s.KubeConfig != "" {
+		flags = append(flags, builder.NewFlag("kubeconfig", s.KubeConfig))
+	}
+	if s.KubeContext != "" {
+		flags = append(flags, builder.NewFlag("kube-context", s.KubeContext))
+	}
+	if s.TillerNamespace != "" {
+		flags = append(flags, builder.NewFlag("tiller-namespace", s.TillerNamespace))
+	}
+	// Talk to a TLS secured Tiller, unless the step passes its own TLS flags
+	if len(s.Arguments) > 0 && tillerCommands[s.Arguments[0]] && !hasTLSFlags(s.Flags) {
+		flags = append(flags, s.tlsFlags()...)
+	}
+	return flags
+}
+
+// tillerCommands are the helm commands that talk to Tiller, and accept the TLS flags.
+var tillerCommands = map[string]bool{
+	"delete":   true,
+	"del":      true,
+	"get":      true,
+	"history":  true,
+	"hist":     true,
+	"install":  true,
+	"list":     true,
+	"ls":       true,
+	"reset":    true,
+	"rollback": true,
+	"status":   true,
+	"test":     true,
+	"upgrade":  true,
+	"version":  true,
+}
+
+func hasTLSFlags(flags builder.Flags) bool {
+	for _, flag := range flags {
+		if flag.Name == "tls" || strings.HasPrefix(flag.Name, "tls-") {
+			return true
+		}
+	}
+	return false
 }
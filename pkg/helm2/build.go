@@ -2,7 +2,6 @@ package helm2
 
 import (
 	"fmt"
-	"strings"
 
 	"get.porter.sh/porter/pkg/exec/builder"
 	"github.com/Masterminds/semver"
@@ -15,29 +14,78 @@ import (
 const clientVersionConstraint string = "^v2.x"
 
 // These values may be referenced elsewhere (init.go), hence consts
-const helmArchiveTmpl string = "helm-%s-linux-amd64.tar.gz"
-const helmDownloadURLTmpl string = "https://get.helm.sh/%s"
-
-const getHelm string = `RUN apt-get update && \
- apt-get install -y curl && \
- curl -o helm.tgz %s && \
- tar -xzf helm.tgz && \
- mv linux-amd64/helm /usr/local/bin && \
- rm helm.tgz
-RUN helm init --client-only
-`
-
-// kubectl may be necessary; for example, to set up RBAC for Helm's Tiller component if needed
+const helmArchiveTmpl string = "helm-%s-%s.tar.gz"
+
+// helmPlatformTmpl is the platform in the name of a helm archive, and the directory it extracts to
+const helmPlatformTmpl string = "linux-%s"
+
+// declareTargetArch makes the architecture of the image being built available to the RUN lines.
+// It is set by BuildKit, and defaults to amd64 for builders that don't set it.
+const declareTargetArch string = "ARG TARGETARCH\n"
+const dockerTargetArch string = "${TARGETARCH:-amd64}"
+const helmDownloadURLTmpl string = "%s/%s"
+
+// kubectl is used to retrieve jsonPath outputs. kubeVersion is installed unless kubectlVersion is set.
 const kubeVersion string = "v1.15.3"
-const getKubectl string = `RUN apt-get update && \
- apt-get install -y apt-transport-https curl && \
- curl -o kubectl https://storage.googleapis.com/kubernetes-release/release/%s/bin/linux/amd64/kubectl && \
- mv kubectl /usr/local/bin && \
- chmod a+x /usr/local/bin/kubectl`
 
 // BuildInput represents stdin passed to the mixin for the build command.
 type BuildInput struct {
 	Config MixinConfig
+
+	// Actions are the steps that use the mixin, by the name of their action
+	Actions map[string][]BuildActionStep
+}
+
+// UnmarshalYAML reads the mixin configuration and the steps of each action. Porter
+// passes the actions under actions, steps at the top level of the input are read as
+// well. Entries that aren't a list of steps are skipped, as the input is porter's to define.
+func (i *BuildInput) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var input struct {
+		Config  MixinConfig            `yaml:"config,omitempty"`
+		Actions map[string]interface{} `yaml:"actions,omitempty"`
+	}
+	err := unmarshal(&input)
+	if err != nil {
+		return err
+	}
+
+	var topLevel map[string]interface{}
+	err = unmarshal(&topLevel)
+	if err != nil {
+		return err
+	}
+	actions := make(map[string]interface{})
+	for name, action := range topLevel {
+		if name != "config" && name != "actions" {
+			actions[name] = action
+		}
+	}
+	for name, action := range input.Actions {
+		actions[name] = action
+	}
+
+	i.Config = input.Config
+	i.Actions = make(map[string][]BuildActionStep)
+	for name, action := range actions {
+		if steps, ok := decodeBuildActionSteps(action); ok {
+			i.Actions[name] = steps
+		}
+	}
+	return nil
+}
+
+// decodeBuildActionSteps reads the steps of an action, returning false when it is not a list of steps.
+func decodeBuildActionSteps(action interface{}) ([]BuildActionStep, bool) {
+	b, err := yaml.Marshal(action)
+	if err != nil {
+		return nil, false
+	}
+	var steps []BuildActionStep
+	err = yaml.Unmarshal(b, &steps)
+	if err != nil {
+		return nil, false
+	}
+	return steps, true
 }
 
 // MixinConfig represents configuration that can be set on the helm mixin in porter.yaml
@@ -46,21 +94,55 @@ type BuildInput struct {
 //	  repositories:
 //	    stable:
 //		  url: "https://charts.helm.sh/stable"
+//	  tillerNamespace: tenant-a
+//	  serviceAccount: tiller
+//	  rbac:
+//	    mode: namespaced
+//	    namespaces:
+//	    - tenant-a-apps
 
 type MixinConfig struct {
-	ClientVersion string `yaml:"clientVersion,omitempty"`
-	Repositories  map[string]Repository
+	Repositories map[string]Repository
+
+	// KubectlVersion is the version of kubectl installed in the invocation image
+	KubectlVersion string `yaml:"kubectlVersion,omitempty"`
+
+	// BaseImageFamily is debian (default), alpine, ubi or distroless
+	BaseImageFamily string `yaml:"baseImageFamily,omitempty"`
+
+	RuntimeConfig `yaml:",inline"`
+}
+
+// GetKubectlVersion returns the version of kubectl installed in the invocation image.
+func (c MixinConfig) GetKubectlVersion() string {
+	if c.KubectlVersion == "" {
+		return kubeVersion
+	}
+	return normalizeKubectlVersion(c.KubectlVersion)
 }
 
 type Repository struct {
 	URL string `yaml:"url,omitempty"`
+
+	// UsernameEnv and PasswordEnv are the environment variables, populated from
+	// bundle credentials, that hold the repository's username and password
+	UsernameEnv string `yaml:"usernameEnv,omitempty"`
+	PasswordEnv string `yaml:"passwordEnv,omitempty"`
+
+	// CAFile, CertFile and KeyFile are the paths of files, such as bundle credentials,
+	// used to verify the repository and authenticate with a client certificate
+	CAFile   string `yaml:"caFile,omitempty"`
+	CertFile string `yaml:"certFile,omitempty"`
+	KeyFile  string `yaml:"keyFile,omitempty"`
 }
 
 func (m *Mixin) Build() error {
 
 	// Create new Builder.
 	var input BuildInput
+	var payload []byte
 	err := builder.LoadAction(m.Context, "", func(contents []byte) (interface{}, error) {
+		payload = contents
 		err := yaml.Unmarshal(contents, &input)
 		return &input, err
 	})
@@ -68,6 +150,11 @@ func (m *Mixin) Build() error {
 		return err
 	}
 
+	err = m.ValidateConfig(payload)
+	if err != nil {
+		return err
+	}
+
 	suppliedClientVersion := input.Config.ClientVersion
 	if suppliedClientVersion != "" {
 		ok, err := validate(suppliedClientVersion, clientVersionConstraint)
@@ -81,46 +168,99 @@ func (m *Mixin) Build() error {
 		m.HelmClientVersion = suppliedClientVersion
 	}
 
-	var helmArchiveVersion = fmt.Sprintf(helmArchiveTmpl, m.HelmClientVersion)
-	var helmDownloadURL = fmt.Sprintf(helmDownloadURLTmpl, helmArchiveVersion)
+	err = input.Config.Tiller.Validate()
+	if err != nil {
+		return err
+	}
 
-	// Define helm
-	fmt.Fprintf(m.Out, getHelm, helmDownloadURL)
+	err = validateRepositories(input.Config.Repositories)
+	if err != nil {
+		return err
+	}
 
-	// Define kubectl
-	fmt.Fprintf(m.Out, getKubectl, kubeVersion)
+	err = input.Config.RBAC.Validate()
+	if err != nil {
+		return err
+	}
 
-	// Go through repositories if defined
-	if len(input.Config.Repositories) > 0 {
-		// Add the repositories
-		for name, repo := range input.Config.Repositories {
-			url := repo.URL
-			repositoryCommand, err := getRepositoryCommand(name, url)
-			if err != nil && m.Debug {
-				fmt.Fprintf(m.Err, "DEBUG: addition of repository failed: %s\n", err.Error())
-			} else {
-				fmt.Fprintf(m.Out, strings.Join(repositoryCommand, " "))
-			}
+	// The default kubectl is kept for bundles that don't pick a version, whatever the helm client
+	if input.Config.InstallKubectl() && input.Config.KubectlVersion != "" {
+		err = validateKubectlVersion(input.Config.GetKubectlVersion(), m.HelmClientVersion)
+		if err != nil {
+			return err
 		}
-		// Make sure we update the helm repositories
-		// So we don't have to do it at runtime
-		fmt.Fprintf(m.Out, "\nRUN helm repo update")
 	}
 
-	return nil
-}
+	err = input.Config.Downloads.Validate()
+	if err != nil {
+		return err
+	}
+
+	err = input.Config.ValidateInitRepos()
+	if err != nil {
+		return err
+	}
 
-func getRepositoryCommand(name, url string) (repositoryCommand []string, err error) {
+	err = validatePlugins(input.Config.Plugins)
+	if err != nil {
+		return err
+	}
+
+	err = input.Config.validateBaseImageFamily()
+	if err != nil {
+		return err
+	}
+
+	localCharts, err := getLocalCharts(input.Actions)
+	if err != nil {
+		return err
+	}
+
+	// Define helm and kubectl
+	family := input.Config.GetBaseImageFamily()
+	m.writeClientInstall(input.Config)
+
+	runtimeConfig := input.Config.RuntimeConfig
 
-	var commandBuilder []string
+	// The repositories that are available in the image, so that their charts can be prefetched
+	buildRepositories := map[string]bool{stableRepository: !runtimeConfig.SkipRepos}
+
+	// Add the repositories in order, so that the image layers are reproducible
+	var added bool
+	for _, name := range sortedRepositoryNames(input.Config.Repositories) {
+		repo := input.Config.Repositories[name]
+		if repo.IsAuthenticated() {
+			// The credentials are not available until the bundle runs, so add the repository then
+			if runtimeConfig.AuthenticatedRepositories == nil {
+				runtimeConfig.AuthenticatedRepositories = make(map[string]Repository)
+			}
+			runtimeConfig.AuthenticatedRepositories[name] = repo
+			buildRepositories[name] = false
+			continue
+		}
 
-	if url == "" {
-		return commandBuilder, fmt.Errorf("repository url must be supplied")
+		fmt.Fprint(m.Out, getRepositoryCommand(family, name, repo.URL))
+		buildRepositories[name] = true
+		added = true
+	}
+	// Make sure we update the helm repositories
+	// So we don't have to do it at runtime
+	if added {
+		fmt.Fprint(m.Out, "\n"+runCommand(family, "helm", "repo", "update"))
 	}
 
-	commandBuilder = append(commandBuilder, "\nRUN", "helm", "repo", "add", name, url)
+	// Build the dependencies of the local charts after the repositories they come from are added
+	m.writeLocalCharts(family, localCharts)
+
+	// Store the charts in the image, so that the bundle doesn't depend on the repositories when it runs
+	m.writePrefetchCharts(family, getPrefetchCharts(input.Actions, buildRepositories))
+
+	// Make the settings needed by the steps available at runtime
+	return m.writeRuntimeConfig(runtimeConfig, family)
+}
 
-	return commandBuilder, nil
+func getRepositoryCommand(family, name, url string) string {
+	return "\n" + runCommand(family, "helm", "repo", "add", name, url)
 }
 
 // validate validates that the supplied clientVersion meets the supplied semver constraint
@@ -2,8 +2,10 @@ package helm2
 
 import (
 	"bytes"
+	"encoding/base64"
 	"fmt"
 	"io/ioutil"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -16,18 +18,20 @@ func TestMixin_Build(t *testing.T) {
 	err := m.Build()
 	require.NoError(t, err)
 
-	buildOutput := `RUN apt-get update && \
- apt-get install -y curl && \
- curl -o helm.tgz https://get.helm.sh/helm-%s-linux-amd64.tar.gz && \
- tar -xzf helm.tgz && \
- mv linux-amd64/helm /usr/local/bin && \
- rm helm.tgz
-RUN helm init --client-only
+	buildOutput := `ARG TARGETARCH
 RUN apt-get update && \
- apt-get install -y apt-transport-https curl && \
- curl -o kubectl https://storage.googleapis.com/kubernetes-release/release/v1.15.3/bin/linux/amd64/kubectl && \
- mv kubectl /usr/local/bin && \
- chmod a+x /usr/local/bin/kubectl`
+ apt-get install -y --no-install-recommends ca-certificates curl && \
+ rm -rf /var/lib/apt/lists/* && \
+ curl -fsSLo helm.tgz https://get.helm.sh/helm-%s-linux-${TARGETARCH:-amd64}.tar.gz && \
+ tar -xzf helm.tgz && \
+ mv linux-${TARGETARCH:-amd64}/helm /usr/local/bin/helm && \
+ rm -rf helm.tgz linux-${TARGETARCH:-amd64} && \
+ curl -fsSLo kubectl https://storage.googleapis.com/kubernetes-release/release/v1.15.3/bin/linux/${TARGETARCH:-amd64}/kubectl && \
+ mv kubectl /usr/local/bin/kubectl && \
+ chmod a+x /usr/local/bin/kubectl && \
+ helm init --client-only`
+
+	fetchOutput := "\nRUN helm fetch stable/mysql --version 0.10.2 --destination /var/lib/helm2-mixin/charts/stable"
 
 	t.Run("build with a valid config", func(t *testing.T) {
 		b, err := ioutil.ReadFile("testdata/build-input-with-valid-config.yaml")
@@ -40,8 +44,9 @@ RUN apt-get update && \
 		err = m.Build()
 		require.NoError(t, err, "build failed")
 		wantOutput := fmt.Sprintf(buildOutput, m.HelmClientVersion) +
-			"\nRUN helm repo add stable stable-charts" +
-			"\nRUN helm repo update"
+			"\nRUN helm repo add stable https://charts.helm.sh/stable" +
+			"\nRUN helm repo update" +
+			fetchOutput
 		gotOutput := m.TestContext.GetOutput()
 		assert.Equal(t, wantOutput, gotOutput)
 	})
@@ -56,12 +61,14 @@ RUN apt-get update && \
 
 		err = m.Build()
 		require.NoError(t, err, "build failed")
+		wantOutput := fmt.Sprintf(buildOutput, m.HelmClientVersion) +
+			"\nRUN helm repo add harbor https://helm.getharbor.io" +
+			"\nRUN helm repo add jetstack https://charts.jetstack.io" +
+			"\nRUN helm repo add stable https://charts.helm.sh/stable" +
+			"\nRUN helm repo update" +
+			fetchOutput
 		gotOutput := m.TestContext.GetOutput()
-		assert.Contains(t, gotOutput, fmt.Sprintf(buildOutput, m.HelmClientVersion))
-		assert.Contains(t, gotOutput, "RUN helm repo add harbor https://helm.getharbor.io")
-		assert.Contains(t, gotOutput, "RUN helm repo add jetstack https://charts.jetstack.io")
-		assert.Contains(t, gotOutput, "RUN helm repo add stable stable-charts")
-		assert.Contains(t, gotOutput, "RUN helm repo update")
+		assert.Equal(t, wantOutput, gotOutput, "the repositories should be added in order")
 	})
 
 	t.Run("build with invalid config", func(t *testing.T) {
@@ -72,14 +79,408 @@ RUN apt-get update && \
 		m.Debug = false
 		m.In = bytes.NewReader(b)
 
+		err = m.Build()
+		require.EqualError(t, err, "invalid mixin configuration: config.repositories.stable: Invalid type. Expected: object, given: null")
+		assert.Empty(t, m.TestContext.GetOutput(), "nothing should be built from an invalid config")
+	})
+
+	t.Run("build with an unknown config setting", func(t *testing.T) {
+		b, err := ioutil.ReadFile("testdata/build-input-with-unknown-config.yaml")
+		require.NoError(t, err)
+
+		m := NewTestMixin(t)
+		m.In = bytes.NewReader(b)
+
+		err = m.Build()
+		require.EqualError(t, err, "invalid mixin configuration: config.repositories.stable: Additional property username is not allowed")
+	})
+
+	t.Run("build with a duplicate repository url", func(t *testing.T) {
+		b, err := ioutil.ReadFile("testdata/build-input-with-duplicate-repo-url.yaml")
+		require.NoError(t, err)
+
+		m := NewTestMixin(t)
+		m.In = bytes.NewReader(b)
+
+		err = m.Build()
+		require.EqualError(t, err, "repositories legacy and stable have the same url https://charts.helm.sh/stable")
+	})
+
+	t.Run("build with runtime config", func(t *testing.T) {
+		b, err := ioutil.ReadFile("testdata/build-input-with-tiller-config.yaml")
+		require.NoError(t, err)
+
+		m := NewTestMixin(t)
+		m.Debug = false
+		m.In = bytes.NewReader(b)
+
+		err = m.Build()
+		require.NoError(t, err, "build failed")
+		savedConfig := base64.StdEncoding.EncodeToString([]byte("tillerNamespace: tenant-a\nserviceAccount: tenant-tiller\n"))
+		wantOutput := fmt.Sprintf(buildOutput, m.HelmClientVersion) + fetchOutput +
+			fmt.Sprintf("\nRUN mkdir -p /etc/helm2-mixin && echo %s | base64 -d > /etc/helm2-mixin/config.yaml", savedConfig)
+		gotOutput := m.TestContext.GetOutput()
+		assert.Equal(t, wantOutput, gotOutput)
+	})
+
+	t.Run("build with invalid rbac config", func(t *testing.T) {
+		b, err := ioutil.ReadFile("testdata/build-input-with-invalid-rbac.yaml")
+		require.NoError(t, err)
+
+		m := NewTestMixin(t)
+		m.In = bytes.NewReader(b)
+
+		err = m.Build()
+		require.EqualError(t, err, `rbac mode "clusterRole" requires clusterRole to be set`)
+	})
+
+	t.Run("build with invalid version policy", func(t *testing.T) {
+		b, err := ioutil.ReadFile("testdata/build-input-with-invalid-version-policy.yaml")
+		require.NoError(t, err)
+
+		m := NewTestMixin(t)
+		m.In = bytes.NewReader(b)
+
+		err = m.Build()
+		require.EqualError(t, err, `tiller versionPolicy "upgradeTiller" requires tiller manage mode "true"`)
+	})
+
+	t.Run("build with a download mirror", func(t *testing.T) {
+		b, err := ioutil.ReadFile("testdata/build-input-with-download-mirror.yaml")
+		require.NoError(t, err)
+
+		m := NewTestMixin(t)
+		m.In = bytes.NewReader(b)
+
+		err = m.Build()
+		require.NoError(t, err, "build failed")
+		savedConfig := base64.StdEncoding.EncodeToString([]byte(`downloads:
+  helm:
+    mirror: https://mirror.example.com/helm/
+    sha256: ab14d3faa25e917efe6e7135d4ecca197866738885a88b9b95d1a16d2bb5b323
+  kubectl:
+    mirror: https://mirror.example.com/kubernetes-release/release
+`))
+		wantOutput := fmt.Sprintf(`ARG TARGETARCH
+RUN apt-get update && \
+ apt-get install -y --no-install-recommends ca-certificates curl && \
+ rm -rf /var/lib/apt/lists/* && \
+ curl -fsSLo helm.tgz https://mirror.example.com/helm/helm-%s-linux-${TARGETARCH:-amd64}.tar.gz && \
+ echo "ab14d3faa25e917efe6e7135d4ecca197866738885a88b9b95d1a16d2bb5b323  helm.tgz" | sha256sum -c - && \
+ tar -xzf helm.tgz && \
+ mv linux-${TARGETARCH:-amd64}/helm /usr/local/bin/helm && \
+ rm -rf helm.tgz linux-${TARGETARCH:-amd64} && \
+ curl -fsSLo kubectl https://mirror.example.com/kubernetes-release/release/v1.15.3/bin/linux/${TARGETARCH:-amd64}/kubectl && \
+ mv kubectl /usr/local/bin/kubectl && \
+ chmod a+x /usr/local/bin/kubectl && \
+ helm init --client-only
+RUN helm fetch stable/mysql --version 0.10.2 --destination /var/lib/helm2-mixin/charts/stable
+RUN mkdir -p /etc/helm2-mixin && echo %s | base64 -d > /etc/helm2-mixin/config.yaml`, m.HelmClientVersion, savedConfig)
+		gotOutput := m.TestContext.GetOutput()
+		assert.Equal(t, wantOutput, gotOutput)
+	})
+
+	t.Run("build with downloaded files in the bundle", func(t *testing.T) {
+		b, err := ioutil.ReadFile("testdata/build-input-with-download-files.yaml")
+		require.NoError(t, err)
+
+		m := NewTestMixin(t)
+		m.In = bytes.NewReader(b)
+
+		err = m.Build()
+		require.NoError(t, err, "build failed")
+		savedConfig := base64.StdEncoding.EncodeToString([]byte(`downloads:
+  helm:
+    file: vendor/helm.tgz
+    sha256: ab14d3faa25e917efe6e7135d4ecca197866738885a88b9b95d1a16d2bb5b323
+  kubectl:
+    file: vendor/kubectl
+    sha256: 7a7f09de08e3dc01c5bbf90657ecc83d5c2da9f5791f1ebe84132b95422878dc
+`))
+		wantOutput := fmt.Sprintf(`ARG TARGETARCH
+COPY vendor/helm.tgz helm.tgz
+COPY vendor/kubectl kubectl
+RUN echo "ab14d3faa25e917efe6e7135d4ecca197866738885a88b9b95d1a16d2bb5b323  helm.tgz" | sha256sum -c - && \
+ tar -xzf helm.tgz && \
+ mv linux-${TARGETARCH:-amd64}/helm /usr/local/bin/helm && \
+ rm -rf helm.tgz linux-${TARGETARCH:-amd64} && \
+ echo "7a7f09de08e3dc01c5bbf90657ecc83d5c2da9f5791f1ebe84132b95422878dc  kubectl" | sha256sum -c - && \
+ mv kubectl /usr/local/bin/kubectl && \
+ chmod a+x /usr/local/bin/kubectl && \
+ helm init --client-only
+RUN helm fetch stable/mysql --version 0.10.2 --destination /var/lib/helm2-mixin/charts/stable
+RUN mkdir -p /etc/helm2-mixin && echo %s | base64 -d > /etc/helm2-mixin/config.yaml`, savedConfig)
+		gotOutput := m.TestContext.GetOutput()
+		assert.Equal(t, wantOutput, gotOutput)
+	})
+
+	t.Run("build with an invalid download", func(t *testing.T) {
+		b, err := ioutil.ReadFile("testdata/build-input-with-invalid-download.yaml")
+		require.NoError(t, err)
+
+		m := NewTestMixin(t)
+		m.In = bytes.NewReader(b)
+
+		err = m.Build()
+		require.EqualError(t, err, `invalid helm download file "../helm.tgz", it must be a relative path inside the bundle directory`)
+	})
+
+	t.Run("build with a kubectl version", func(t *testing.T) {
+		b, err := ioutil.ReadFile("testdata/build-input-with-kubectl-version.yaml")
+		require.NoError(t, err)
+
+		m := NewTestMixin(t)
+		m.In = bytes.NewReader(b)
+
+		err = m.Build()
+		require.NoError(t, err, "build failed")
+		wantOutput := strings.Replace(fmt.Sprintf(buildOutput, m.HelmClientVersion), "/v1.15.3/", "/v1.16.3/", 1) + fetchOutput
+		gotOutput := m.TestContext.GetOutput()
+		assert.Equal(t, wantOutput, gotOutput)
+	})
+
+	t.Run("build without kubectl", func(t *testing.T) {
+		b, err := ioutil.ReadFile("testdata/build-input-without-kubectl.yaml")
+		require.NoError(t, err)
+
+		m := NewTestMixin(t)
+		m.In = bytes.NewReader(b)
+
+		err = m.Build()
+		require.NoError(t, err, "build failed")
+		gotOutput := m.TestContext.GetOutput()
+		assert.NotContains(t, gotOutput, "kubectl https://")
+		savedConfig := base64.StdEncoding.EncodeToString([]byte("kubectl: false\n"))
+		assert.Contains(t, gotOutput, savedConfig, "the runtime should know that kubectl is not installed")
+	})
+
+	t.Run("build with an unsupported kubectl version", func(t *testing.T) {
+		b, err := ioutil.ReadFile("testdata/build-input-with-unsupported-kubectl-version.yaml")
+		require.NoError(t, err)
+
+		m := NewTestMixin(t)
+		m.In = bytes.NewReader(b)
+
+		err = m.Build()
+		require.EqualError(t, err, "kubectlVersion v1.20.0 is not within the supported skew of helm v2.17.0, "+
+			"which supports Kubernetes 1.16: use kubectl 1.15 through 1.17")
+	})
+
+	t.Run("build with an older helm client version and the default kubectl", func(t *testing.T) {
+		b, err := ioutil.ReadFile("testdata/build-input-with-older-client-version.yaml")
+		require.NoError(t, err)
+
+		m := NewTestMixin(t)
+		m.In = bytes.NewReader(b)
+
+		err = m.Build()
+		require.NoError(t, err, "the skew should only be checked when kubectlVersion is set")
+		savedConfig := base64.StdEncoding.EncodeToString([]byte("clientVersion: v2.13.1\n"))
+		wantOutput := fmt.Sprintf(buildOutput, "v2.13.1") + fetchOutput +
+			fmt.Sprintf("\nRUN mkdir -p /etc/helm2-mixin && echo %s | base64 -d > /etc/helm2-mixin/config.yaml", savedConfig)
+		gotOutput := m.TestContext.GetOutput()
+		assert.Equal(t, wantOutput, gotOutput)
+	})
+
+	t.Run("build with a partial kubectl version", func(t *testing.T) {
+		b, err := ioutil.ReadFile("testdata/build-input-with-partial-kubectl-version.yaml")
+		require.NoError(t, err)
+
+		m := NewTestMixin(t)
+		m.In = bytes.NewReader(b)
+
+		err = m.Build()
+		require.EqualError(t, err, `supplied kubectlVersion "v1.16" must be a full version, such as v1.15.3`)
+		assert.Empty(t, m.TestContext.GetOutput(), "kubectl should not be downloaded from a release that doesn't exist")
+	})
+
+	t.Run("build with a base image family", func(t *testing.T) {
+		for _, family := range []string{"debian", "alpine", "ubi", "distroless"} {
+			t.Run(family, func(t *testing.T) {
+				b, err := ioutil.ReadFile(fmt.Sprintf("testdata/build-input-with-base-image-%s.yaml", family))
+				require.NoError(t, err)
+
+				m := NewTestMixin(t)
+				m.In = bytes.NewReader(b)
+
+				err = m.Build()
+				require.NoError(t, err, "build failed")
+
+				wantOutput, err := ioutil.ReadFile(fmt.Sprintf("testdata/build-output-%s.txt", family))
+				require.NoError(t, err)
+				gotOutput := m.TestContext.GetOutput()
+				assert.Equal(t, string(wantOutput), gotOutput)
+			})
+		}
+	})
+
+	t.Run("build with an invalid base image family", func(t *testing.T) {
+		b, err := ioutil.ReadFile("testdata/build-input-with-invalid-base-image.yaml")
+		require.NoError(t, err)
+
+		m := NewTestMixin(t)
+		m.In = bytes.NewReader(b)
+
+		err = m.Build()
+		require.EqualError(t, err, `invalid baseImageFamily "scratch", allowed values are: debian, alpine, ubi, distroless`)
+	})
+
+	t.Run("build with an authenticated repository", func(t *testing.T) {
+		b, err := ioutil.ReadFile("testdata/build-input-with-authenticated-repo.yaml")
+		require.NoError(t, err)
+
+		m := NewTestMixin(t)
+		m.In = bytes.NewReader(b)
+
 		err = m.Build()
 		require.NoError(t, err, "build failed")
+		savedConfig := base64.StdEncoding.EncodeToString([]byte(`authenticatedRepositories:
+  private:
+    url: https://charts.example.com
+    usernameEnv: CHARTS_USERNAME
+    passwordEnv: CHARTS_PASSWORD
+    caFile: /cnab/app/charts-ca.pem
+`))
 		wantOutput := fmt.Sprintf(buildOutput, m.HelmClientVersion) +
-			"\nRUN helm repo update"
+			fmt.Sprintf("\nRUN mkdir -p /etc/helm2-mixin && echo %s | base64 -d > /etc/helm2-mixin/config.yaml", savedConfig)
+		gotOutput := m.TestContext.GetOutput()
+		assert.Equal(t, wantOutput, gotOutput, "the repository should be added when the bundle runs")
+	})
+
+	t.Run("build with plugins", func(t *testing.T) {
+		b, err := ioutil.ReadFile("testdata/build-input-with-plugins.yaml")
+		require.NoError(t, err)
+
+		m := NewTestMixin(t)
+		m.In = bytes.NewReader(b)
+
+		err = m.Build()
+		require.NoError(t, err, "build failed")
+		savedConfig := base64.StdEncoding.EncodeToString([]byte(`plugins:
+- name: diff
+  source: https://github.com/databus23/helm-diff
+  version: v3.1.3
+- name: lint
+  source: plugins/lint
+`))
+		wantOutput := fmt.Sprintf(`ARG TARGETARCH
+COPY plugins/lint /var/lib/helm2-mixin/plugins/lint
+RUN apt-get update && \
+ apt-get install -y --no-install-recommends ca-certificates curl git && \
+ rm -rf /var/lib/apt/lists/* && \
+ curl -fsSLo helm.tgz https://get.helm.sh/helm-%s-linux-${TARGETARCH:-amd64}.tar.gz && \
+ tar -xzf helm.tgz && \
+ mv linux-${TARGETARCH:-amd64}/helm /usr/local/bin/helm && \
+ rm -rf helm.tgz linux-${TARGETARCH:-amd64} && \
+ curl -fsSLo kubectl https://storage.googleapis.com/kubernetes-release/release/v1.15.3/bin/linux/${TARGETARCH:-amd64}/kubectl && \
+ mv kubectl /usr/local/bin/kubectl && \
+ chmod a+x /usr/local/bin/kubectl && \
+ helm init --client-only && \
+ helm plugin install https://github.com/databus23/helm-diff --version v3.1.3 && \
+ helm plugin install /var/lib/helm2-mixin/plugins/lint
+RUN helm fetch stable/mysql --version 0.10.2 --destination /var/lib/helm2-mixin/charts/stable
+RUN mkdir -p /etc/helm2-mixin && echo %s | base64 -d > /etc/helm2-mixin/config.yaml`, m.HelmClientVersion, savedConfig)
 		gotOutput := m.TestContext.GetOutput()
 		assert.Equal(t, wantOutput, gotOutput)
 	})
 
+	t.Run("build with a local chart", func(t *testing.T) {
+		b, err := ioutil.ReadFile("testdata/build-input-with-local-chart.yaml")
+		require.NoError(t, err)
+
+		m := NewTestMixin(t)
+		m.In = bytes.NewReader(b)
+
+		err = m.Build()
+		require.NoError(t, err, "build failed")
+		wantOutput := fmt.Sprintf(buildOutput, m.HelmClientVersion) +
+			"\nRUN helm repo add stable https://charts.helm.sh/stable" +
+			"\nRUN helm repo update" +
+			"\nCOPY charts/myapp /cnab/app/charts/myapp" +
+			"\nRUN helm dependency build /cnab/app/charts/myapp"
+		gotOutput := m.TestContext.GetOutput()
+		assert.Equal(t, wantOutput, gotOutput, "the chart should be copied once, after the repositories are added")
+	})
+
+	t.Run("build with porter's actions", func(t *testing.T) {
+		b, err := ioutil.ReadFile("testdata/build-input-with-porter-actions.yaml")
+		require.NoError(t, err)
+
+		m := NewTestMixin(t)
+		m.In = bytes.NewReader(b)
+
+		err = m.Build()
+		require.NoError(t, err, "build failed")
+		wantOutput := fmt.Sprintf(buildOutput, m.HelmClientVersion) +
+			"\nRUN helm repo add bitnami https://charts.bitnami.com/bitnami" +
+			"\nRUN helm repo update" +
+			"\nCOPY charts/myapp /cnab/app/charts/myapp" +
+			"\nRUN helm dependency build /cnab/app/charts/myapp" +
+			"\nRUN helm fetch bitnami/redis --version 10.5.7 --destination /var/lib/helm2-mixin/charts/bitnami" +
+			fetchOutput
+		gotOutput := m.TestContext.GetOutput()
+		assert.Equal(t, wantOutput, gotOutput, "the charts of the helm2 steps in each action should be built into the image")
+	})
+
+	t.Run("build with chart versions that can't be prefetched", func(t *testing.T) {
+		b, err := ioutil.ReadFile("testdata/build-input-with-unresolved-chart-versions.yaml")
+		require.NoError(t, err)
+
+		m := NewTestMixin(t)
+		m.In = bytes.NewReader(b)
+
+		err = m.Build()
+		require.NoError(t, err, "build failed")
+		wantOutput := fmt.Sprintf(buildOutput, m.HelmClientVersion) +
+			"\nRUN helm repo add bitnami https://charts.bitnami.com/bitnami" +
+			"\nRUN helm repo update" +
+			fetchOutput
+		gotOutput := m.TestContext.GetOutput()
+		assert.Equal(t, wantOutput, gotOutput, "templated versions, version constraints and charts from repositories that are not added during the build should be resolved when the bundle runs")
+	})
+
+	t.Run("build with a stable repo url", func(t *testing.T) {
+		b, err := ioutil.ReadFile("testdata/build-input-with-stable-repo-url.yaml")
+		require.NoError(t, err)
+
+		m := NewTestMixin(t)
+		m.In = bytes.NewReader(b)
+
+		err = m.Build()
+		require.NoError(t, err, "build failed")
+		savedConfig := base64.StdEncoding.EncodeToString([]byte("stableRepoURL: https://charts.helm.sh/stable\n"))
+		wantOutput := fmt.Sprintf(buildOutput, m.HelmClientVersion) + " --stable-repo-url https://charts.helm.sh/stable" +
+			fmt.Sprintf("\nRUN mkdir -p /etc/helm2-mixin && echo %s | base64 -d > /etc/helm2-mixin/config.yaml", savedConfig)
+		gotOutput := m.TestContext.GetOutput()
+		assert.Equal(t, wantOutput, gotOutput, "the stable repo url should be used by helm init in the image and when the bundle runs")
+	})
+
+	t.Run("build with skipped repos", func(t *testing.T) {
+		b, err := ioutil.ReadFile("testdata/build-input-with-skip-repos.yaml")
+		require.NoError(t, err)
+
+		m := NewTestMixin(t)
+		m.In = bytes.NewReader(b)
+
+		err = m.Build()
+		require.NoError(t, err, "build failed")
+		gotOutput := m.TestContext.GetOutput()
+		assert.Contains(t, gotOutput, `RUN ["/usr/local/bin/helm", "init", "--client-only", "--skip-repos"]`)
+		assert.Contains(t, gotOutput, "\nENV HELM2_MIXIN_CONFIG="+base64.StdEncoding.EncodeToString([]byte("skipRepos: true\n")))
+		assert.NotContains(t, gotOutput, `"fetch"`, "the stable repository is not added, so its charts can't be prefetched")
+	})
+
+	t.Run("build with invalid tiller storage", func(t *testing.T) {
+		b, err := ioutil.ReadFile("testdata/build-input-with-invalid-tiller-storage.yaml")
+		require.NoError(t, err)
+
+		m := NewTestMixin(t)
+		m.In = bytes.NewReader(b)
+
+		err = m.Build()
+		require.EqualError(t, err, `invalid tiller storage "sql", allowed values are: configmap, secret`)
+	})
+
 	t.Run("build with a defined helm client version", func(t *testing.T) {
 
 		b, err := ioutil.ReadFile("testdata/build-input-with-supported-client-version.yaml")
@@ -90,7 +491,9 @@ RUN apt-get update && \
 		m.In = bytes.NewReader(b)
 		err = m.Build()
 		require.NoError(t, err, "build failed")
-		wantOutput := fmt.Sprintf(buildOutput, m.HelmClientVersion)
+		savedConfig := base64.StdEncoding.EncodeToString([]byte("clientVersion: v2.16.1\n"))
+		wantOutput := fmt.Sprintf(buildOutput, "v2.16.1") + fetchOutput +
+			fmt.Sprintf("\nRUN mkdir -p /etc/helm2-mixin && echo %s | base64 -d > /etc/helm2-mixin/config.yaml", savedConfig)
 		gotOutput := m.TestContext.GetOutput()
 		assert.Equal(t, wantOutput, gotOutput)
 	})
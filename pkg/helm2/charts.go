@@ -0,0 +1,161 @@
+package helm2
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// bundleDir is where the bundle directory is copied in the invocation image
+const bundleDir string = "/cnab/app"
+
+// prefetchedChartsDir is where Build stores the charts referenced by the bundle,
+// in a directory for each repository
+const prefetchedChartsDir string = "/var/lib/helm2-mixin/charts"
+
+// stableRepository is the repository that helm init adds, unless skipRepos is set
+const stableRepository string = "stable"
+
+// exactChartVersion matches a semver version without a v prefix, the version that helm fetch
+// puts in the name of the archive. Constraints, such as ^1.0, and templates are not matched.
+var exactChartVersion = regexp.MustCompile(`^[0-9]+\.[0-9]+\.[0-9]+(-[0-9A-Za-z.-]+)?(\+[0-9A-Za-z.-]+)?$`)
+
+// BuildStep is the part of an install or upgrade step that Build needs to prefetch its chart.
+type BuildStep struct {
+	Chart   string `yaml:"chart,omitempty"`
+	Version string `yaml:"version,omitempty"`
+}
+
+// BuildActionStep is a step of an action in the build input.
+type BuildActionStep struct {
+	BuildStep `yaml:"helm2"`
+}
+
+// chartReference is a chart in a repository, at a specific version
+type chartReference struct {
+	Repository string
+	Name       string
+	Version    string
+}
+
+// parseChartReference splits a repository chart reference, such as stable/mysql,
+// returning false for local charts, URLs and versions that are not exact, which can't be prefetched.
+func parseChartReference(chart, version string) (chartReference, bool) {
+	if !exactChartVersion.MatchString(version) || strings.Contains(chart, "://") || strings.HasPrefix(chart, ".") || strings.HasPrefix(chart, "/") {
+		return chartReference{}, false
+	}
+	parts := strings.Split(chart, "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return chartReference{}, false
+	}
+	return chartReference{Repository: parts[0], Name: parts[1], Version: version}, true
+}
+
+// Path is where the chart is stored in the invocation image.
+func (c chartReference) Path() string {
+	return path.Join(prefetchedChartsDir, c.Repository, fmt.Sprintf("%s-%s.tgz", c.Name, c.Version))
+}
+
+// getPrefetchCharts finds the versioned repository charts used by the actions, from the
+// repositories that are added during the build. Charts from other repositories, such as
+// those that require credentials, are resolved when the bundle runs.
+func getPrefetchCharts(actions map[string][]BuildActionStep, repositories map[string]bool) []chartReference {
+	seen := make(map[chartReference]bool)
+	var charts []chartReference
+	for _, steps := range actions {
+		for _, step := range steps {
+			ref, ok := parseChartReference(step.Chart, step.Version)
+			if !ok || seen[ref] {
+				continue
+			}
+			if !repositories[ref.Repository] {
+				continue
+			}
+			seen[ref] = true
+			charts = append(charts, ref)
+		}
+	}
+
+	sort.Slice(charts, func(i, j int) bool {
+		return charts[i].Path() < charts[j].Path()
+	})
+	return charts
+}
+
+// writePrefetchCharts prints the Dockerfile lines that store the charts in the invocation image.
+func (m *Mixin) writePrefetchCharts(family string, charts []chartReference) {
+	for _, c := range charts {
+		fmt.Fprint(m.Out, "\n"+runCommand(family, "helm", "fetch", c.Repository+"/"+c.Name,
+			"--version", c.Version, "--destination", path.Join(prefetchedChartsDir, c.Repository)))
+	}
+}
+
+// isLocalChart returns whether the chart is a directory in the bundle, such as ./charts/myapp
+func isLocalChart(chart string) bool {
+	return strings.HasPrefix(chart, "./")
+}
+
+// localChartPath is where a local chart is stored in the invocation image.
+func localChartPath(chart string) string {
+	return path.Join(bundleDir, chart)
+}
+
+// getLocalCharts finds the local charts used by the actions, relative to the bundle directory.
+func getLocalCharts(actions map[string][]BuildActionStep) ([]string, error) {
+	seen := make(map[string]bool)
+	var charts []string
+	for _, steps := range actions {
+		for _, step := range steps {
+			if !isLocalChart(step.Chart) {
+				continue
+			}
+			chart := path.Clean(step.Chart)
+			if chart == "." || chart == ".." || strings.HasPrefix(chart, "../") {
+				return nil, errors.Errorf("invalid chart %q, a local chart must be a directory inside the bundle directory", step.Chart)
+			}
+			if seen[chart] {
+				continue
+			}
+			seen[chart] = true
+			charts = append(charts, chart)
+		}
+	}
+
+	sort.Strings(charts)
+	return charts, nil
+}
+
+// writeLocalCharts prints the Dockerfile lines that copy the local charts into the
+// invocation image, and download the charts they depend on into their charts directory.
+func (m *Mixin) writeLocalCharts(family string, charts []string) {
+	for _, c := range charts {
+		fmt.Fprintf(m.Out, "\nCOPY %s %s", c, localChartPath(c))
+		fmt.Fprint(m.Out, "\n"+runCommand(family, "helm", "dependency", "build", localChartPath(c)))
+	}
+}
+
+// resolveChart returns the path of a local chart in the bundle directory, or the chart
+// that was stored in the invocation image by Build, or the original chart when it was not prefetched.
+func (m *Mixin) resolveChart(chart, version string) (string, error) {
+	if isLocalChart(chart) {
+		return localChartPath(chart), nil
+	}
+
+	ref, ok := parseChartReference(chart, version)
+	if !ok {
+		return chart, nil
+	}
+
+	exists, err := m.FileSystem.Exists(ref.Path())
+	if err != nil {
+		return "", errors.Wrapf(err, "could not check for a prefetched chart at %s", ref.Path())
+	}
+	if !exists {
+		return chart, nil
+	}
+	return ref.Path(), nil
+}
@@ -0,0 +1,160 @@
+package helm2
+
+import (
+	"bytes"
+	"os"
+	"testing"
+
+	"get.porter.sh/porter/pkg/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	yaml "gopkg.in/yaml.v2"
+)
+
+func TestParseChartReference(t *testing.T) {
+	ref, ok := parseChartReference("stable/mysql", "0.10.2")
+	require.True(t, ok)
+	assert.Equal(t, chartReference{Repository: "stable", Name: "mysql", Version: "0.10.2"}, ref)
+	assert.Equal(t, "/var/lib/helm2-mixin/charts/stable/mysql-0.10.2.tgz", ref.Path())
+
+	for _, chart := range []string{"./charts/mysql", "/cnab/app/charts/mysql", "https://charts.example.com/mysql-0.10.2.tgz", "mysql"} {
+		_, ok := parseChartReference(chart, "0.10.2")
+		assert.False(t, ok, chart)
+	}
+
+	_, ok = parseChartReference("stable/mysql", "")
+	assert.False(t, ok, "charts without a version should be resolved when the bundle runs")
+
+	for _, version := range []string{"^1.0", "~0.10", ">=0.10.2", "0.10", "v0.10.2", "{{ bundle.parameters.chart-version }}"} {
+		_, ok := parseChartReference("stable/mysql", version)
+		assert.False(t, ok, "only exact versions can be prefetched: %s", version)
+	}
+
+	ref, ok = parseChartReference("stable/mysql", "1.0.0-rc.1+build.2")
+	require.True(t, ok)
+	assert.Equal(t, "/var/lib/helm2-mixin/charts/stable/mysql-1.0.0-rc.1+build.2.tgz", ref.Path())
+}
+
+func TestGetPrefetchCharts(t *testing.T) {
+	b := []byte(`
+install:
+- helm2:
+    chart: stable/mysql
+    version: 0.10.2
+- helm2:
+    chart: private/app
+    version: 1.0.0
+upgrade:
+- helm2:
+    chart: stable/mysql
+    version: 0.10.2
+- helm2:
+    chart: bitnami/redis
+    version: 10.5.7
+- helm2:
+    chart: unknown/app
+    version: 1.0.0
+uninstall:
+- helm2:
+    releases:
+    - mysql
+`)
+	var input BuildInput
+	err := yaml.Unmarshal(b, &input)
+	require.NoError(t, err)
+
+	charts := getPrefetchCharts(input.Actions, map[string]bool{"stable": true, "bitnami": true, "private": false})
+	want := []chartReference{
+		{Repository: "bitnami", Name: "redis", Version: "10.5.7"},
+		{Repository: "stable", Name: "mysql", Version: "0.10.2"},
+	}
+	assert.Equal(t, want, charts)
+}
+
+func TestBuildInput_UnmarshalActions(t *testing.T) {
+	b := []byte(`
+config:
+  clientVersion: v2.16.1
+actions:
+  install:
+  - exec:
+      command: ./prepare.sh
+  - helm2:
+      chart: stable/mysql
+      version: 0.10.2
+  status: not a list of steps
+upgrade:
+- helm2:
+    chart: bitnami/redis
+    version: 10.5.7
+`)
+	var input BuildInput
+	err := yaml.Unmarshal(b, &input)
+	require.NoError(t, err)
+
+	assert.Equal(t, "v2.16.1", input.Config.ClientVersion)
+	assert.Equal(t, map[string][]BuildActionStep{
+		"install": {{}, {BuildStep{Chart: "stable/mysql", Version: "0.10.2"}}},
+		"upgrade": {{BuildStep{Chart: "bitnami/redis", Version: "10.5.7"}}},
+	}, input.Actions, "the steps under actions and at the top level should be read, skipping entries that aren't steps")
+}
+
+func TestMixin_Install_PrefetchedChart(t *testing.T) {
+	os.Setenv(test.ExpectedCommandEnv, "helm install --name mysql /var/lib/helm2-mixin/charts/stable/mysql-0.10.2.tgz --version 0.10.2")
+	defer os.Unsetenv(test.ExpectedCommandEnv)
+
+	h := NewTestMixin(t)
+	err := h.FileSystem.WriteFile("/var/lib/helm2-mixin/charts/stable/mysql-0.10.2.tgz", []byte("chart"), 0644)
+	require.NoError(t, err)
+
+	action := InstallAction{Steps: []InstallStep{{InstallArguments: InstallArguments{
+		Step:    Step{Description: "Install MySQL"},
+		Name:    "mysql",
+		Chart:   "stable/mysql",
+		Version: "0.10.2",
+	}}}}
+	b, err := yaml.Marshal(action)
+	require.NoError(t, err)
+	h.In = bytes.NewReader(b)
+
+	err = h.Install()
+	require.NoError(t, err)
+}
+
+func TestGetLocalCharts(t *testing.T) {
+	actions := map[string][]BuildActionStep{
+		"install": {
+			{BuildStep{Chart: "./charts/myapp"}},
+			{BuildStep{Chart: "./charts/db/"}},
+			{BuildStep{Chart: "stable/mysql", Version: "0.10.2"}},
+		},
+		"upgrade": {
+			{BuildStep{Chart: "./charts/myapp"}},
+		},
+	}
+	charts, err := getLocalCharts(actions)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"charts/db", "charts/myapp"}, charts)
+
+	actions["install"] = append(actions["install"], BuildActionStep{BuildStep{Chart: "./../other/chart"}})
+	_, err = getLocalCharts(actions)
+	assert.EqualError(t, err, `invalid chart "./../other/chart", a local chart must be a directory inside the bundle directory`)
+}
+
+func TestMixin_Install_LocalChart(t *testing.T) {
+	os.Setenv(test.ExpectedCommandEnv, "helm install --name myapp /cnab/app/charts/myapp")
+	defer os.Unsetenv(test.ExpectedCommandEnv)
+
+	h := NewTestMixin(t)
+	action := InstallAction{Steps: []InstallStep{{InstallArguments: InstallArguments{
+		Step:  Step{Description: "Install My App"},
+		Name:  "myapp",
+		Chart: "./charts/myapp",
+	}}}}
+	b, err := yaml.Marshal(action)
+	require.NoError(t, err)
+	h.In = bytes.NewReader(b)
+
+	err = h.Install()
+	require.NoError(t, err)
+}
@@ -0,0 +1,57 @@
+package helm2
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+)
+
+// defaultKubeConfig is where bundles conventionally mount the kubeconfig credential
+const defaultKubeConfig string = "/root/.kube/config"
+
+// inClusterKubeConfig is an empty kubeconfig, so that helm and kubectl fall back to the
+// pod's service account, like the mixin's kubernetes client, instead of a mounted kubeconfig
+const inClusterKubeConfig string = "/dev/null"
+
+// newHelmCommand creates a helm command that targets the cluster and Tiller
+// selected for the current step.
+func (m *Mixin) newHelmCommand(args ...string) *exec.Cmd {
+	cmd := m.NewCommand(m.getHelmClientPath(), args...)
+	if m.Kubernetes.KubeContext != "" {
+		cmd.Args = append(cmd.Args, "--kube-context", m.Kubernetes.KubeContext)
+	}
+	if m.Tiller.TillerNamespace != "" {
+		cmd.Args = append(cmd.Args, "--tiller-namespace", m.Tiller.TillerNamespace)
+	}
+	m.setKubeConfigEnv(cmd)
+	return cmd
+}
+
+// newKubectlCommand creates a kubectl command that targets the cluster selected
+// by the current step's KubernetesSettings.
+func (m *Mixin) newKubectlCommand(args ...string) *exec.Cmd {
+	cmd := m.NewCommand("kubectl", args...)
+	if m.Kubernetes.KubeContext != "" {
+		cmd.Args = append(cmd.Args, "--context", m.Kubernetes.KubeContext)
+	}
+	m.setKubeConfigEnv(cmd)
+	return cmd
+}
+
+// setKubeConfigEnv points the command at the step's kubeconfig, so that the
+// child process and the mixin's kubernetes client use the same cluster.
+func (m *Mixin) setKubeConfigEnv(cmd *exec.Cmd) {
+	kubeConfig := m.Kubernetes.KubeConfig
+	if m.Kubernetes.InCluster {
+		kubeConfig = inClusterKubeConfig
+	}
+	if kubeConfig == "" {
+		return
+	}
+
+	// A nil Env inherits the current environment, keep that behavior when adding to it
+	if cmd.Env == nil {
+		cmd.Env = os.Environ()
+	}
+	cmd.Env = append(cmd.Env, fmt.Sprintf("KUBECONFIG=%s", kubeConfig))
+}
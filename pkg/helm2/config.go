@@ -0,0 +1,131 @@
+package helm2
+
+import (
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// runtimeConfigPath is where Build saves the RuntimeConfig in the invocation image
+const runtimeConfigPath string = "/etc/helm2-mixin/config.yaml"
+
+const saveRuntimeConfig string = "\nRUN mkdir -p %s && echo %s | base64 -d > %s"
+
+// runtimeConfigEnv holds the base64 encoded RuntimeConfig on images without a
+// shell to write runtimeConfigPath
+const runtimeConfigEnv string = "HELM2_MIXIN_CONFIG"
+
+// RuntimeConfig is the part of the MixinConfig that is used when the bundle runs.
+// Build saves it into the invocation image so that the steps don't need to repeat it.
+type RuntimeConfig struct {
+	// ClientVersion is the version of the helm client installed in the invocation image
+	ClientVersion string `yaml:"clientVersion,omitempty"`
+
+	TillerSettings `yaml:",inline"`
+	Tiller         TillerConfig `yaml:"tiller,omitempty"`
+	RBAC           RBACConfig   `yaml:"rbac,omitempty"`
+
+	// TLSGenerate secures a Tiller installed by the mixin with generated certificates
+	TLSGenerate bool `yaml:"tlsGenerate,omitempty"`
+
+	// Kubectl installs kubectl in the invocation image when true (default). It is
+	// required by outputs that use jsonPath.
+	Kubectl *bool `yaml:"kubectl,omitempty"`
+
+	// StableRepoURL replaces the stable repository that helm init adds
+	StableRepoURL string `yaml:"stableRepoURL,omitempty"`
+
+	// SkipRepos stops helm init from adding the stable and local repositories
+	SkipRepos bool `yaml:"skipRepos,omitempty"`
+
+	// SensitiveKeys are set keys whose values are masked in the commands that the
+	// mixin prints, in addition to keys that contain password, token, secret or key
+	SensitiveKeys []string `yaml:"sensitiveKeys,omitempty"`
+
+	// Plugins are installed in the invocation image, and checked before the steps that run them
+	Plugins []Plugin `yaml:"plugins,omitempty"`
+
+	// AuthenticatedRepositories are added before each install or upgrade, when their
+	// credentials are available. Build saves the repositories that require credentials here.
+	AuthenticatedRepositories map[string]Repository `yaml:"authenticatedRepositories,omitempty"`
+
+	// Downloads selects where the helm and kubectl clients are downloaded from
+	Downloads DownloadsConfig `yaml:"downloads,omitempty"`
+}
+
+// writeRuntimeConfig prints the Dockerfile line that saves the runtime configuration
+// into the invocation image. Nothing is printed when the defaults are used.
+func (m *Mixin) writeRuntimeConfig(cfg RuntimeConfig, family string) error {
+	b, err := yaml.Marshal(cfg)
+	if err != nil {
+		return errors.Wrap(err, "could not marshal the runtime configuration")
+	}
+	if string(b) == "{}\n" {
+		return nil
+	}
+
+	encoded := base64.StdEncoding.EncodeToString(b)
+	if family == baseImageDistroless {
+		fmt.Fprintf(m.Out, "\nENV %s=%s", runtimeConfigEnv, encoded)
+		return nil
+	}
+	fmt.Fprintf(m.Out, saveRuntimeConfig, filepath.Dir(runtimeConfigPath), encoded, runtimeConfigPath)
+	return nil
+}
+
+// loadRuntimeConfig reads the runtime configuration saved by Build, returning
+// the defaults when the bundle did not configure the mixin.
+func (m *Mixin) loadRuntimeConfig() (RuntimeConfig, error) {
+	var cfg RuntimeConfig
+
+	if encoded := os.Getenv(runtimeConfigEnv); encoded != "" {
+		b, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return cfg, errors.Wrapf(err, "could not decode the mixin configuration in %s", runtimeConfigEnv)
+		}
+		err = yaml.Unmarshal(b, &cfg)
+		if err != nil {
+			return cfg, errors.Wrapf(err, "could not unmarshal the mixin configuration in %s", runtimeConfigEnv)
+		}
+		return cfg, nil
+	}
+
+	exists, err := m.FileSystem.Exists(runtimeConfigPath)
+	if err != nil {
+		return cfg, errors.Wrapf(err, "could not check for the mixin configuration at %s", runtimeConfigPath)
+	}
+	if !exists {
+		return cfg, nil
+	}
+
+	b, err := m.FileSystem.ReadFile(runtimeConfigPath)
+	if err != nil {
+		return cfg, errors.Wrapf(err, "could not read the mixin configuration at %s", runtimeConfigPath)
+	}
+	err = yaml.Unmarshal(b, &cfg)
+	if err != nil {
+		return cfg, errors.Wrapf(err, "could not unmarshal the mixin configuration at %s", runtimeConfigPath)
+	}
+	return cfg, nil
+}
+
+// prepareStep selects the cluster and Tiller used by the current step. Settings
+// made on the step take precedence over the mixin configuration.
+func (m *Mixin) prepareStep(kube KubernetesSettings, tiller TillerSettings) error {
+	cfg, err := m.loadRuntimeConfig()
+	if err != nil {
+		return err
+	}
+
+	m.Config = cfg
+	if cfg.ClientVersion != "" {
+		m.HelmClientVersion = cfg.ClientVersion
+	}
+	m.Kubernetes = kube
+	m.Tiller = cfg.TillerSettings.Merge(tiller)
+	return nil
+}
@@ -0,0 +1,105 @@
+package helm2
+
+import (
+	"encoding/base64"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	yaml "gopkg.in/yaml.v2"
+)
+
+func TestMixin_LoadRuntimeConfig(t *testing.T) {
+	t.Run("defaults", func(t *testing.T) {
+		m := NewTestMixin(t)
+
+		cfg, err := m.loadRuntimeConfig()
+		require.NoError(t, err)
+		assert.Equal(t, RuntimeConfig{}, cfg)
+	})
+
+	t.Run("saved by build", func(t *testing.T) {
+		m := NewTestMixin(t)
+		err := m.FileSystem.WriteFile(runtimeConfigPath, []byte("tillerNamespace: tenant-a\nserviceAccount: tenant-tiller\n"), 0644)
+		require.NoError(t, err)
+
+		cfg, err := m.loadRuntimeConfig()
+		require.NoError(t, err)
+		assert.Equal(t, "tenant-a", cfg.TillerNamespace)
+		assert.Equal(t, "tenant-tiller", cfg.ServiceAccount)
+	})
+
+	t.Run("saved in the environment", func(t *testing.T) {
+		os.Setenv(runtimeConfigEnv, base64.StdEncoding.EncodeToString([]byte("tillerNamespace: tenant-a\n")))
+		defer os.Unsetenv(runtimeConfigEnv)
+		m := NewTestMixin(t)
+
+		cfg, err := m.loadRuntimeConfig()
+		require.NoError(t, err)
+		assert.Equal(t, "tenant-a", cfg.TillerNamespace)
+	})
+}
+
+func TestRuntimeConfig_UnmarshalTillerManage(t *testing.T) {
+	var cfg RuntimeConfig
+	err := yaml.Unmarshal([]byte("tiller:\n  manage: false\n"), &cfg)
+	require.NoError(t, err)
+	assert.Equal(t, tillerManageFalse, cfg.Tiller.GetManage())
+
+	err = yaml.Unmarshal([]byte("tiller:\n  manage: verify-only\n"), &cfg)
+	require.NoError(t, err)
+	assert.Equal(t, tillerManageVerifyOnly, cfg.Tiller.GetManage())
+}
+
+func TestMixin_PrepareStep(t *testing.T) {
+	m := NewTestMixin(t)
+	err := m.FileSystem.WriteFile(runtimeConfigPath, []byte("tillerNamespace: tenant-a\nserviceAccount: tenant-tiller\n"), 0644)
+	require.NoError(t, err)
+
+	err = m.prepareStep(KubernetesSettings{KubeContext: "mycontext"}, TillerSettings{TillerNamespace: "tenant-b"})
+	require.NoError(t, err)
+
+	assert.Equal(t, "mycontext", m.Kubernetes.KubeContext)
+	assert.Equal(t, "tenant-b", m.Tiller.GetTillerNamespace(), "the step should override the mixin configuration")
+	assert.Equal(t, "tenant-tiller", m.Tiller.GetServiceAccount(), "the mixin configuration should be used when the step doesn't set a value")
+}
+
+func TestMixin_PrepareStep_DisableTLS(t *testing.T) {
+	m := NewTestMixin(t)
+	err := m.FileSystem.WriteFile(runtimeConfigPath, []byte("tls: true\ntlsVerify: true\ntlsCaCert: /cnab/app/ca.pem\n"), 0644)
+	require.NoError(t, err)
+
+	disabled := false
+	err = m.prepareStep(KubernetesSettings{}, TillerSettings{TLS: &disabled, TLSVerify: &disabled})
+	require.NoError(t, err)
+
+	assert.False(t, m.Tiller.TLSEnabled(), "the step should turn off TLS")
+	assert.False(t, m.Tiller.VerifyTLS(), "the step should turn off TLS verification")
+	assert.Empty(t, m.Tiller.TLSFlags())
+
+	err = m.prepareStep(KubernetesSettings{}, TillerSettings{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"--tls", "--tls-verify", "--tls-ca-cert", "/cnab/app/ca.pem"}, m.Tiller.TLSFlags(),
+		"the mixin configuration should be used when the step doesn't set a value")
+}
+
+func TestMixin_WriteRuntimeConfig(t *testing.T) {
+	cfg := RuntimeConfig{TillerSettings: TillerSettings{TillerNamespace: "tenant-a"}}
+	encoded := base64.StdEncoding.EncodeToString([]byte("tillerNamespace: tenant-a\n"))
+
+	m := NewTestMixin(t)
+	err := m.writeRuntimeConfig(cfg, baseImageDebian)
+	require.NoError(t, err)
+	assert.Equal(t, "\nRUN mkdir -p /etc/helm2-mixin && echo "+encoded+" | base64 -d > /etc/helm2-mixin/config.yaml", m.TestContext.GetOutput())
+
+	m = NewTestMixin(t)
+	err = m.writeRuntimeConfig(cfg, baseImageDistroless)
+	require.NoError(t, err)
+	assert.Equal(t, "\nENV HELM2_MIXIN_CONFIG="+encoded, m.TestContext.GetOutput(), "images without a shell should use the environment")
+
+	m = NewTestMixin(t)
+	err = m.writeRuntimeConfig(RuntimeConfig{}, baseImageDebian)
+	require.NoError(t, err)
+	assert.Empty(t, m.TestContext.GetOutput())
+}
@@ -0,0 +1,184 @@
+package helm2
+
+import (
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// The families of base images that Build can install the helm and kubectl clients on
+const (
+	// baseImageDebian installs packages with apt-get, it is the default because porter's base image is debian
+	baseImageDebian string = "debian"
+
+	// baseImageAlpine installs packages with apk
+	baseImageAlpine string = "alpine"
+
+	// baseImageUBI installs packages with microdnf, as found on the ubi-minimal images
+	baseImageUBI string = "ubi"
+
+	// baseImageDistroless has no shell or package manager, so the clients are copied from images
+	baseImageDistroless string = "distroless"
+)
+
+// The images that the clients are copied from on distroless base images
+const (
+	distrolessHelmImageTmpl    string = "alpine/helm:%s"
+	distrolessHelmPath         string = "/usr/bin/helm"
+	distrolessKubectlImageTmpl string = "bitnami/kubectl:%s"
+	distrolessKubectlPath      string = "/opt/bitnami/kubectl/bin/kubectl"
+)
+
+const helmBinPath string = "/usr/local/bin/helm"
+const kubectlBinPath string = "/usr/local/bin/kubectl"
+
+// runContinuation separates the commands that are combined into a single RUN line
+const runContinuation string = " && \\\n "
+
+// GetBaseImageFamily returns the family of the invocation image's base image, defaulting to debian.
+func (c MixinConfig) GetBaseImageFamily() string {
+	if c.BaseImageFamily == "" {
+		return baseImageDebian
+	}
+	return c.BaseImageFamily
+}
+
+// validateBaseImageFamily checks that the family is known and supports the other build settings.
+func (c MixinConfig) validateBaseImageFamily() error {
+	switch c.GetBaseImageFamily() {
+	case baseImageDebian, baseImageAlpine, baseImageUBI:
+		return nil
+	case baseImageDistroless:
+		if c.Downloads != (DownloadsConfig{}) {
+			return errors.Errorf("downloads are not supported with baseImageFamily %s, the clients are copied from the %s and %s images",
+				baseImageDistroless, strings.Split(distrolessHelmImageTmpl, ":")[0], strings.Split(distrolessKubectlImageTmpl, ":")[0])
+		}
+		if len(c.Plugins) > 0 {
+			return errors.Errorf("plugins are not supported with baseImageFamily %s, installing them requires a shell", baseImageDistroless)
+		}
+		return nil
+	default:
+		return errors.Errorf("invalid baseImageFamily %q, allowed values are: %s", c.BaseImageFamily,
+			strings.Join([]string{baseImageDebian, baseImageAlpine, baseImageUBI, baseImageDistroless}, ", "))
+	}
+}
+
+// runCommand formats a RUN line for the base image family. Distroless images
+// have no shell, so the exec form is used for them.
+func runCommand(family string, args ...string) string {
+	if family != baseImageDistroless {
+		return "RUN " + strings.Join(args, " ")
+	}
+
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		quoted[i] = strconv.Quote(arg)
+	}
+	return "RUN [" + strings.Join(quoted, ", ") + "]"
+}
+
+// installPackages returns the commands that install the packages needed to
+// download and extract the clients with the family's package manager. Plugins
+// are installed with git, and their install hooks commonly download with curl.
+func installPackages(family string, download bool, remotePlugins bool) []string {
+	var packages []string
+	if download || remotePlugins {
+		packages = append(packages, "curl")
+	}
+	if remotePlugins {
+		packages = append(packages, "git")
+	}
+
+	switch family {
+	case baseImageAlpine:
+		if len(packages) == 0 {
+			return nil
+		}
+		return []string{"apk add --no-cache ca-certificates " + strings.Join(packages, " ")}
+	case baseImageUBI:
+		packages = append(packages, "tar", "gzip")
+		return []string{"microdnf install -y " + strings.Join(packages, " "), "microdnf clean all"}
+	default:
+		if len(packages) == 0 {
+			return nil
+		}
+		return []string{
+			"apt-get update",
+			"apt-get install -y --no-install-recommends ca-certificates " + strings.Join(packages, " "),
+			"rm -rf /var/lib/apt/lists/*",
+		}
+	}
+}
+
+// writeClientInstall prints the Dockerfile lines that install the helm and kubectl
+// clients, using a single RUN line.
+func (m *Mixin) writeClientInstall(cfg MixinConfig) {
+	family := cfg.GetBaseImageFamily()
+	installKubectl := cfg.InstallKubectl()
+	kubectlVersion := cfg.GetKubectlVersion()
+
+	if family == baseImageDistroless {
+		fmt.Fprintf(m.Out, "COPY --from=%s %s %s\n",
+			fmt.Sprintf(distrolessHelmImageTmpl, strings.TrimPrefix(m.HelmClientVersion, "v")), distrolessHelmPath, helmBinPath)
+		if installKubectl {
+			fmt.Fprintf(m.Out, "COPY --from=%s %s %s\n",
+				fmt.Sprintf(distrolessKubectlImageTmpl, strings.TrimPrefix(kubectlVersion, "v")), distrolessKubectlPath, kubectlBinPath)
+		}
+		initArgs := append([]string{helmBinPath, "init", "--client-only"}, cfg.InitRepoFlags()...)
+		fmt.Fprint(m.Out, runCommand(family, initArgs...))
+		return
+	}
+
+	// Select the clients for the architecture of the image
+	fmt.Fprint(m.Out, declareTargetArch)
+
+	helmSource := cfg.Downloads.Helm
+	kubectlSource := cfg.Downloads.Kubectl
+	if helmSource.File != "" {
+		fmt.Fprintf(m.Out, "COPY %s helm.tgz\n", helmSource.File)
+	}
+	if installKubectl && kubectlSource.File != "" {
+		fmt.Fprintf(m.Out, "COPY %s kubectl\n", kubectlSource.File)
+	}
+	for _, p := range cfg.Plugins {
+		if !p.IsRemote() {
+			fmt.Fprintf(m.Out, "COPY %s %s\n", p.Source, path.Join(bundledPluginsDir, p.Name))
+		}
+	}
+
+	download := helmSource.File == "" || (installKubectl && kubectlSource.File == "")
+	commands := installPackages(family, download, hasRemotePlugins(cfg.Plugins))
+
+	// Define helm
+	helmPlatform := fmt.Sprintf(helmPlatformTmpl, dockerTargetArch)
+	if helmSource.File == "" {
+		helmArchive := fmt.Sprintf(helmArchiveTmpl, m.HelmClientVersion, helmPlatform)
+		helmDownloadURL := fmt.Sprintf(helmDownloadURLTmpl, helmSource.GetMirror(defaultHelmMirror), helmArchive)
+		commands = append(commands, "curl -fsSLo helm.tgz "+helmDownloadURL)
+	}
+	commands = append(commands, helmSource.checksumCommands("helm.tgz")...)
+	commands = append(commands,
+		"tar -xzf helm.tgz",
+		fmt.Sprintf("mv %s/helm %s", helmPlatform, helmBinPath),
+		fmt.Sprintf("rm -rf helm.tgz %s", helmPlatform))
+
+	// Define kubectl, unless the bundle has opted out
+	if installKubectl {
+		if kubectlSource.File == "" {
+			kubectlDownloadURL := fmt.Sprintf(kubectlDownloadURLTmpl, kubectlSource.GetMirror(defaultKubectlMirror), kubectlVersion, dockerTargetArch)
+			commands = append(commands, "curl -fsSLo kubectl "+kubectlDownloadURL)
+		}
+		commands = append(commands, kubectlSource.checksumCommands("kubectl")...)
+		commands = append(commands,
+			"mv kubectl "+kubectlBinPath,
+			"chmod a+x "+kubectlBinPath)
+	}
+
+	initCommand := append([]string{"helm", "init", "--client-only"}, cfg.InitRepoFlags()...)
+	commands = append(commands, strings.Join(initCommand, " "))
+	commands = append(commands, pluginInstallCommands(cfg.Plugins)...)
+	fmt.Fprint(m.Out, runCommand(family, strings.Join(commands, runContinuation)))
+}
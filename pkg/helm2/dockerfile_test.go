@@ -0,0 +1,37 @@
+package helm2
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunCommand(t *testing.T) {
+	assert.Equal(t, "RUN helm repo add stable https://charts.helm.sh/stable",
+		runCommand(baseImageAlpine, "helm", "repo", "add", "stable", "https://charts.helm.sh/stable"))
+	assert.Equal(t, `RUN ["helm", "repo", "add", "stable", "https://charts.helm.sh/stable"]`,
+		runCommand(baseImageDistroless, "helm", "repo", "add", "stable", "https://charts.helm.sh/stable"))
+}
+
+func TestMixinConfig_ValidateBaseImageFamily(t *testing.T) {
+	assert.NoError(t, MixinConfig{}.validateBaseImageFamily())
+	assert.NoError(t, MixinConfig{BaseImageFamily: baseImageDistroless}.validateBaseImageFamily())
+
+	cfg := MixinConfig{BaseImageFamily: baseImageDistroless}
+	cfg.Downloads.Helm.Mirror = "https://mirror.example.com/helm"
+	assert.EqualError(t, cfg.validateBaseImageFamily(),
+		"downloads are not supported with baseImageFamily distroless, the clients are copied from the alpine/helm and bitnami/kubectl images")
+
+	cfg = MixinConfig{BaseImageFamily: baseImageDistroless}
+	cfg.Plugins = []Plugin{{Name: "diff", Source: "https://github.com/databus23/helm-diff", Version: "v3.1.3"}}
+	assert.EqualError(t, cfg.validateBaseImageFamily(),
+		"plugins are not supported with baseImageFamily distroless, installing them requires a shell")
+}
+
+func TestInstallPackages(t *testing.T) {
+	assert.Empty(t, installPackages(baseImageDebian, false, false), "debian has tar, so nothing is needed for bundled files")
+	assert.Equal(t, []string{"microdnf install -y tar gzip", "microdnf clean all"}, installPackages(baseImageUBI, false, false),
+		"ubi-minimal needs tar and gzip to extract helm")
+	assert.Equal(t, []string{"apk add --no-cache ca-certificates curl git"}, installPackages(baseImageAlpine, false, true),
+		"plugins are installed with git")
+}
@@ -0,0 +1,94 @@
+package helm2
+
+import (
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// kubectlDownloadURLTmpl is where kubectl is downloaded from, relative to the mirror
+const kubectlDownloadURLTmpl string = "%s/%s/bin/linux/%s/kubectl"
+
+const defaultHelmMirror string = "https://get.helm.sh"
+const defaultKubectlMirror string = "https://storage.googleapis.com/kubernetes-release/release"
+
+// verifyChecksum fails the build when a file doesn't match its pinned checksum
+const verifyChecksum string = "echo \"%s  %s\" | sha256sum -c -"
+
+// DownloadsConfig controls where Build gets the helm and kubectl clients, for
+// example from a mirror or the bundle directory when building without internet access.
+type DownloadsConfig struct {
+	Helm    DownloadSource `yaml:"helm,omitempty"`
+	Kubectl DownloadSource `yaml:"kubectl,omitempty"`
+}
+
+// DownloadSource is where a file is downloaded from, and the checksum that it must match.
+type DownloadSource struct {
+	// Mirror is the base URL that replaces the official download site
+	Mirror string `yaml:"mirror,omitempty"`
+
+	// File is the path to the file in the bundle directory, used instead of downloading it
+	File string `yaml:"file,omitempty"`
+
+	// SHA256 is the checksum of the file, verified when the image is built
+	SHA256 string `yaml:"sha256,omitempty"`
+}
+
+// GetMirror returns the mirror's base URL, defaulting to the official download site.
+func (s DownloadSource) GetMirror(defaultMirror string) string {
+	if s.Mirror == "" {
+		return defaultMirror
+	}
+	return strings.TrimSuffix(s.Mirror, "/")
+}
+
+// checksumCommands returns the commands that verify the file against the pinned
+// checksum, or nothing when no checksum is pinned.
+func (s DownloadSource) checksumCommands(path string) []string {
+	if s.SHA256 == "" {
+		return nil
+	}
+	return []string{fmt.Sprintf(verifyChecksum, strings.ToLower(s.SHA256), path)}
+}
+
+// Validate checks that the source is either a mirror or a file, and that the
+// checksum is a SHA-256.
+func (s DownloadSource) Validate(name string) error {
+	if s.Mirror != "" && s.File != "" {
+		return errors.Errorf("the %s download may set either mirror or file, not both", name)
+	}
+
+	if s.Mirror != "" {
+		u, err := url.Parse(s.Mirror)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return errors.Errorf("invalid %s download mirror %q, it must be an http or https URL", name, s.Mirror)
+		}
+	}
+
+	if s.File != "" {
+		cleaned := filepath.ToSlash(filepath.Clean(s.File))
+		if filepath.IsAbs(s.File) || cleaned == ".." || strings.HasPrefix(cleaned, "../") {
+			return errors.Errorf("invalid %s download file %q, it must be a relative path inside the bundle directory", name, s.File)
+		}
+	}
+
+	if s.SHA256 != "" {
+		_, err := parseChecksum(s.SHA256)
+		if err != nil {
+			return errors.Wrapf(err, "invalid %s download sha256", name)
+		}
+	}
+	return nil
+}
+
+// Validate checks the helm and kubectl download sources.
+func (c DownloadsConfig) Validate() error {
+	err := c.Helm.Validate("helm")
+	if err != nil {
+		return err
+	}
+	return c.Kubectl.Validate("kubectl")
+}
@@ -0,0 +1,44 @@
+package helm2
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDownloadSource_Validate(t *testing.T) {
+	checksum := "ab14d3faa25e917efe6e7135d4ecca197866738885a88b9b95d1a16d2bb5b323"
+	testcases := map[string]struct {
+		source  DownloadSource
+		wantErr string
+	}{
+		"defaults":        {source: DownloadSource{}},
+		"mirror":          {source: DownloadSource{Mirror: "https://mirror.example.com/helm", SHA256: checksum}},
+		"file":            {source: DownloadSource{File: "vendor/helm.tgz", SHA256: checksum}},
+		"mirror and file": {source: DownloadSource{Mirror: "https://mirror.example.com", File: "helm.tgz"}, wantErr: "the helm download may set either mirror or file, not both"},
+		"relative mirror": {source: DownloadSource{Mirror: "mirror.example.com/helm"}, wantErr: `invalid helm download mirror "mirror.example.com/helm", it must be an http or https URL`},
+		"absolute file":   {source: DownloadSource{File: "/tmp/helm.tgz"}, wantErr: `invalid helm download file "/tmp/helm.tgz", it must be a relative path inside the bundle directory`},
+		"escaping file":   {source: DownloadSource{File: "vendor/../../helm.tgz"}, wantErr: `invalid helm download file "vendor/../../helm.tgz", it must be a relative path inside the bundle directory`},
+		"short checksum":  {source: DownloadSource{SHA256: "abc123"}, wantErr: `invalid helm download sha256: invalid SHA-256 checksum "abc123"`},
+	}
+	for name, tc := range testcases {
+		t.Run(name, func(t *testing.T) {
+			err := tc.source.Validate("helm")
+			if tc.wantErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestDownloadSource_GetMirror(t *testing.T) {
+	assert.Equal(t, defaultHelmMirror, DownloadSource{}.GetMirror(defaultHelmMirror))
+	assert.Equal(t, "https://mirror.example.com/helm", DownloadSource{Mirror: "https://mirror.example.com/helm/"}.GetMirror(defaultHelmMirror))
+}
+
+func TestDownloadSource_ChecksumCommands(t *testing.T) {
+	assert.Empty(t, DownloadSource{}.checksumCommands("helm.tgz"))
+	assert.Equal(t, []string{`echo "abc  helm.tgz" | sha256sum -c -`}, DownloadSource{SHA256: "ABC"}.checksumCommands("helm.tgz"))
+}
@@ -6,31 +6,62 @@ import (
 	yaml "gopkg.in/yaml.v2"
 )
 
-func (m *Mixin) loadAction() (*Action, error) {
+// loadAction reads the step, and adds its sensitive values to the masker, because
+// porter prints the step in debug mode and the command in errors.
+func (m *Mixin) loadAction(masker *maskingWriter) (*Action, error) {
+	cfg, err := m.loadRuntimeConfig()
+	if err != nil {
+		return nil, err
+	}
+
 	var action Action
-	err := builder.LoadAction(m.Context, "", func(contents []byte) (interface{}, error) {
+	err = builder.LoadAction(m.Context, "", func(contents []byte) (interface{}, error) {
 		err := yaml.Unmarshal(contents, &action)
+		for _, step := range action.Steps {
+			masker.addSecrets(cfg.sensitiveFlagValues(step.Arguments, step.Flags)...)
+		}
 		return &action, err
 	})
 	return &action, err
 }
 
 func (m *Mixin) Execute() error {
-	action, err := m.loadAction()
+	masker := &maskingWriter{w: m.Err}
+	m.Err = masker
+	defer func() { m.Err = masker.w }()
+
+	action, err := m.loadAction(masker)
 	if err != nil {
-		return err
+		return errors.New(masker.mask(err.Error()))
 	}
 	if len(action.Steps) != 1 {
 		return errors.Errorf("expected a single step, but got %d", len(action.Steps))
 	}
 	step := action.Steps[0]
 
+	err = m.prepareStep(step.KubernetesSettings, step.TillerSettings)
+	if err != nil {
+		return err
+	}
+	err = m.loadTLSCertificates()
+	if err != nil {
+		return errors.Wrap(err, "failed to load the Tiller certificates")
+	}
+	// Pass the Tiller from the mixin configuration along to the helm command
+	action.Steps[0].TillerSettings = m.Tiller
+
+	err = m.checkPlugin(step.Arguments)
+	if err != nil {
+		return err
+	}
+
 	_, err = builder.ExecuteSingleStepAction(m.Context, action)
 	if err != nil {
-		return errors.Wrapf(err, "invocation of action %s failed", action)
+		err = errors.Wrapf(err, "invocation of action %s failed", action.Name)
+		return errors.New(masker.mask(err.Error()))
 	}
 
-	kubeClient, err := m.getKubernetesClient("/root/.kube/config")
+	kubeClient, err := m.getKubernetesClient()
 	if err != nil {
 		return errors.Wrap(err, "couldn't get kubernetes client")
 	}
@@ -11,6 +11,8 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	yaml "gopkg.in/yaml.v2"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 func TestMixin_UnmarshalExecuteStep(t *testing.T) {
@@ -75,3 +77,110 @@ func TestMixin_Execute(t *testing.T) {
 	err := h.Execute()
 	require.NoError(t, err)
 }
+
+func TestExecuteStep_GetFlags(t *testing.T) {
+	step := ExecuteStep{
+		ExecuteInstruction: ExecuteInstruction{
+			KubernetesSettings: KubernetesSettings{
+				KubeConfig:  "/cnab/app/kubeconfig",
+				KubeContext: "mycontext",
+			},
+			Flags: builder.Flags{builder.NewFlag("o", "yaml")},
+		},
+	}
+
+	wantFlags := builder.Flags{
+		builder.NewFlag("o", "yaml"),
+		builder.NewFlag("kubeconfig", "/cnab/app/kubeconfig"),
+		builder.NewFlag("kube-context", "mycontext"),
+	}
+	assert.Equal(t, wantFlags, step.GetFlags())
+	assert.Len(t, step.Flags, 1, "GetFlags should not modify the step's flags")
+}
+
+func TestExecuteStep_GetFlags_InCluster(t *testing.T) {
+	step := ExecuteStep{
+		ExecuteInstruction: ExecuteInstruction{
+			KubernetesSettings: KubernetesSettings{
+				KubeConfig: "/cnab/app/kubeconfig",
+				InCluster:  true,
+			},
+		},
+	}
+
+	wantFlags := builder.Flags{builder.NewFlag("kubeconfig", "/dev/null")}
+	assert.Equal(t, wantFlags, step.GetFlags(), "helm should use the pod's service account, and not a mounted kubeconfig")
+}
+
+func TestMixin_NewHelmCommand_InCluster(t *testing.T) {
+	h := NewTestMixin(t)
+	h.Kubernetes = KubernetesSettings{KubeConfig: "/cnab/app/kubeconfig", InCluster: true}
+
+	cmd := h.newHelmCommand("status", "mysql")
+	assert.Equal(t, "KUBECONFIG=/dev/null", cmd.Env[len(cmd.Env)-1],
+		"helm should use the pod's service account, like the mixin's kubernetes client")
+
+	cmd = h.newKubectlCommand("get", "pods")
+	assert.Equal(t, "KUBECONFIG=/dev/null", cmd.Env[len(cmd.Env)-1])
+}
+
+func TestExecuteStep_GetFlags_TLS(t *testing.T) {
+	enabled := true
+	tiller := TillerSettings{TLSVerify: &enabled, TLSCACert: "/cnab/app/ca.pem"}
+
+	step := ExecuteStep{
+		ExecuteInstruction: ExecuteInstruction{
+			TillerSettings: tiller,
+			Arguments:      []string{"status", "mysql"},
+		},
+	}
+	wantFlags := builder.Flags{
+		builder.NewFlag("tls"),
+		builder.NewFlag("tls-verify"),
+		builder.NewFlag("tls-ca-cert", "/cnab/app/ca.pem"),
+	}
+	assert.Equal(t, wantFlags, step.GetFlags(), "helm should use TLS to talk to Tiller")
+
+	step.Arguments = []string{"repo", "list"}
+	assert.Empty(t, step.GetFlags(), "commands that don't talk to Tiller don't accept the TLS flags")
+
+	step.Arguments = []string{"status", "mysql"}
+	step.Flags = builder.Flags{builder.NewFlag("tls")}
+	assert.Equal(t, step.Flags, step.GetFlags(), "the step's own TLS flags should be used as is")
+}
+
+func TestMixin_Execute_TLSLoadsCertificates(t *testing.T) {
+	defer os.Unsetenv(test.ExpectedCommandEnv)
+	os.Setenv(test.ExpectedCommandEnv, "helm status mysql --tls --tls-ca-cert /root/.helm/tls/ca.crt "+
+		"--tls-cert /root/.helm/tls/client.crt --tls-hostname tiller-server --tls-key /root/.helm/tls/client.key --tls-verify")
+
+	h := NewTestMixin(t)
+	err := h.FileSystem.WriteFile(runtimeConfigPath, []byte("tlsGenerate: true\n"), 0644)
+	require.NoError(t, err)
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: tlsSecretName, Namespace: "kube-system"},
+		Data: map[string][]byte{
+			tlsCACertKey:     []byte("ca"),
+			tlsClientCertKey: []byte("cert"),
+			tlsClientKeyKey:  []byte("key"),
+		},
+	}
+	_, err = h.KubeClient.CoreV1().Secrets("kube-system").Create(secret)
+	require.NoError(t, err)
+
+	b, _ := yaml.Marshal(Action{
+		Name: "status",
+		Steps: []ExecuteStep{
+			{ExecuteInstruction: ExecuteInstruction{Arguments: []string{"status", "mysql"}}},
+		},
+	})
+	h.In = bytes.NewReader(b)
+
+	err = h.Execute()
+	require.NoError(t, err)
+
+	cert, err := h.FileSystem.ReadFile("/root/.helm/tls/client.crt")
+	require.NoError(t, err)
+	assert.Equal(t, "cert", string(cert), "helm should use the certificates generated when Tiller was installed")
+}
@@ -25,6 +25,18 @@ type Mixin struct {
 	ClientFactory kubernetes.ClientFactory
 	TillerIniter
 	HelmClientVersion string
+
+	// HelmClientPath is the helm binary for HelmClientVersion, defaults to helm on the PATH
+	HelmClientPath string
+
+	// Config is the mixin configuration saved in the invocation image by Build
+	Config RuntimeConfig
+
+	// Kubernetes selects the cluster for the step being executed
+	Kubernetes KubernetesSettings
+
+	// Tiller selects the Tiller for the step being executed
+	Tiller TillerSettings
 }
 
 // New helm2 mixin client, initialized with useful defaults.
@@ -54,6 +66,28 @@ func (m *Mixin) ValidatePayload(b []byte) error {
 	if err != nil {
 		return errors.Wrap(err, "could not marshal payload as yaml")
 	}
+	return m.validateSchema(s)
+}
+
+// ValidateConfig checks the mixin configuration in the build input against the schema.
+func (m *Mixin) ValidateConfig(b []byte) error {
+	var input struct {
+		Config map[string]interface{} `json:"config,omitempty"`
+	}
+	err := yaml.Unmarshal(b, &input)
+	if err != nil {
+		return errors.Wrap(err, "could not marshal build input as yaml")
+	}
+	if input.Config == nil {
+		return nil
+	}
+
+	err = m.validateSchema(map[string]interface{}{"config": input.Config})
+	return errors.Wrap(err, "invalid mixin configuration")
+}
+
+// validateSchema validates a payload, loaded as a go dump, against the mixin schema.
+func (m *Mixin) validateSchema(s map[string]interface{}) error {
 	manifestLoader := gojsonschema.NewGoLoader(s)
 
 	// Load the step schema
@@ -84,6 +118,14 @@ func (m *Mixin) ValidatePayload(b []byte) error {
 	return nil
 }
 
-func (m *Mixin) getKubernetesClient(kubeconfig string) (k8s.Interface, error) {
-	return m.ClientFactory.GetClient(kubeconfig)
+func (m *Mixin) getKubernetesClient() (k8s.Interface, error) {
+	cfg := kubernetes.Config{
+		KubeConfig: m.Kubernetes.KubeConfig,
+		Context:    m.Kubernetes.KubeContext,
+		InCluster:  m.Kubernetes.InCluster,
+	}
+	if cfg.KubeConfig == "" {
+		cfg.KubeConfig = defaultKubeConfig
+	}
+	return m.ClientFactory.GetClient(cfg)
 }
@@ -0,0 +1,162 @@
+package helm2
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/mholt/archiver"
+	"github.com/pkg/errors"
+)
+
+// helmClientCacheDir holds one helm client binary per version, in a directory named for the version
+const helmClientCacheDir string = "/root/.helm/clients"
+
+// helmChecksumURLTmpl is the checksum that helm publishes next to each archive
+const helmChecksumURLTmpl string = "%s.sha256"
+
+func (m *Mixin) getHelmClientPath() string {
+	if m.HelmClientPath == "" {
+		return "helm"
+	}
+	return m.HelmClientPath
+}
+
+// getCachedHelmClientPath returns where the helm client for a version is cached.
+func getCachedHelmClientPath(version string) string {
+	return filepath.Join(helmClientCacheDir, version, "helm")
+}
+
+// installHelmClient returns the path to a helm client binary for the version. A
+// binary already in the image is preferred, otherwise it is downloaded to the cache.
+func (r RealTillerIniter) installHelmClient(m *Mixin, version string) (string, error) {
+	binPath := getCachedHelmClientPath(version)
+	exists, err := m.FileSystem.Exists(binPath)
+	if err != nil {
+		return "", errors.Wrapf(err, "unable to check for a cached helm client at %s", binPath)
+	}
+	if exists {
+		return binPath, nil
+	}
+
+	mirror := m.Config.Downloads.Helm.GetMirror(defaultHelmMirror)
+	platform := fmt.Sprintf(helmPlatformTmpl, runtime.GOARCH)
+	archiveURL := fmt.Sprintf(helmDownloadURLTmpl, mirror, fmt.Sprintf(helmArchiveTmpl, version, platform))
+	fmt.Fprintf(m.Out, "Downloading helm client %s from %s\n", version, archiveURL)
+	err = m.downloadHelmClient(archiveURL, platform, binPath)
+	if err != nil {
+		return "", err
+	}
+	return binPath, nil
+}
+
+// downloadHelmClient downloads a helm release archive for the platform, verifies
+// it against its published checksum and extracts the helm binary to binPath.
+func (m *Mixin) downloadHelmClient(archiveURL string, platform string, binPath string) error {
+	wantChecksum, err := fetchChecksum(fmt.Sprintf(helmChecksumURLTmpl, archiveURL))
+	if err != nil {
+		return err
+	}
+
+	tmpDir, err := m.FileSystem.TempDir("", "helm")
+	if err != nil {
+		return errors.Wrap(err, "unable to create a temporary directory for downloading the helm client binary")
+	}
+	defer m.FileSystem.RemoveAll(tmpDir)
+
+	archivePath := filepath.Join(tmpDir, filepath.Base(archiveURL))
+	gotChecksum, err := m.downloadFile(archiveURL, archivePath)
+	if err != nil {
+		return err
+	}
+	if gotChecksum != wantChecksum {
+		return errors.Errorf("checksum mismatch for %s: expected %s but got %s", archiveURL, wantChecksum, gotChecksum)
+	}
+
+	unarchivedDir := filepath.Join(tmpDir, "unarchived")
+	err = archiver.Unarchive(archivePath, unarchivedDir)
+	if err != nil {
+		return errors.Wrap(err, "unable to unarchive the helm client download")
+	}
+
+	// Stage the binary next to its final location so that the cache never holds a partial file
+	err = m.FileSystem.MkdirAll(filepath.Dir(binPath), 0755)
+	if err != nil {
+		return errors.Wrapf(err, "unable to create the helm client cache directory %s", filepath.Dir(binPath))
+	}
+	stagedPath := binPath + ".tmp"
+	err = m.FileSystem.Rename(filepath.Join(unarchivedDir, platform, "helm"), stagedPath)
+	if err != nil {
+		return errors.Wrapf(err, "unable to cache the helm client binary at %q", binPath)
+	}
+	err = m.FileSystem.Chmod(stagedPath, 0755)
+	if err != nil {
+		return errors.Wrapf(err, "unable to make the helm client binary at %q executable", binPath)
+	}
+	return m.FileSystem.Rename(stagedPath, binPath)
+}
+
+// downloadFile saves the contents of the url to dest, returning its SHA-256 checksum.
+func (m *Mixin) downloadFile(url string, dest string) (string, error) {
+	res, err := http.Get(url)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to download %s", url)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return "", errors.Errorf("failed to download %s: %s", url, res.Status)
+	}
+
+	f, err := m.FileSystem.OpenFile(dest, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return "", errors.Wrapf(err, "unable to create %s", dest)
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	_, err = io.Copy(io.MultiWriter(f, h), res.Body)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to download %s", url)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// fetchChecksum retrieves a published SHA-256 checksum. The file may contain
+// only the checksum, or the checksum followed by the file name.
+func fetchChecksum(url string) (string, error) {
+	res, err := http.Get(url)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to download checksum %s", url)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return "", errors.Errorf("failed to download checksum %s: %s", url, res.Status)
+	}
+
+	b, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to download checksum %s", url)
+	}
+	return parseChecksum(string(b))
+}
+
+func parseChecksum(contents string) (string, error) {
+	fields := strings.Fields(contents)
+	if len(fields) == 0 {
+		return "", errors.New("checksum file is empty")
+	}
+
+	checksum := strings.ToLower(fields[0])
+	decoded, err := hex.DecodeString(checksum)
+	if err != nil || len(decoded) != sha256.Size {
+		return "", errors.Errorf("invalid SHA-256 checksum %q", fields[0])
+	}
+	return checksum, nil
+}
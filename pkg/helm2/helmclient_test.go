@@ -0,0 +1,88 @@
+package helm2
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseChecksum(t *testing.T) {
+	const sum = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+	got, err := parseChecksum(sum + "\n")
+	require.NoError(t, err)
+	assert.Equal(t, sum, got)
+
+	got, err = parseChecksum(sum + "  helm-v2.17.0-linux-amd64.tar.gz\n")
+	require.NoError(t, err)
+	assert.Equal(t, sum, got, "the file name after the checksum should be ignored")
+
+	_, err = parseChecksum("")
+	assert.EqualError(t, err, "checksum file is empty")
+
+	_, err = parseChecksum("abc123")
+	assert.EqualError(t, err, `invalid SHA-256 checksum "abc123"`)
+}
+
+func TestFetchChecksum_NotFound(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+
+	_, err := fetchChecksum(server.URL + "/helm.tar.gz.sha256")
+	assert.EqualError(t, err, fmt.Sprintf("failed to download checksum %s/helm.tar.gz.sha256: 404 Not Found", server.URL))
+}
+
+func TestMixin_DownloadHelmClient_ChecksumMismatch(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/helm.tar.gz.sha256" {
+			fmt.Fprintln(w, "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855")
+			return
+		}
+		fmt.Fprint(w, "not the real archive")
+	}))
+	defer server.Close()
+
+	m := NewTestMixin(t)
+	err := m.downloadHelmClient(server.URL+"/helm.tar.gz", "linux-amd64", getCachedHelmClientPath("v2.16.1"))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "checksum mismatch")
+
+	exists, err := m.FileSystem.Exists(getCachedHelmClientPath("v2.16.1"))
+	require.NoError(t, err)
+	assert.False(t, exists, "a binary that failed verification should not be cached")
+}
+
+func TestRealTillerIniter_InstallHelmClient_Cached(t *testing.T) {
+	m := NewTestMixin(t)
+	err := m.FileSystem.WriteFile(getCachedHelmClientPath("v2.16.1"), []byte("#!/bin/sh"), 0755)
+	require.NoError(t, err)
+
+	path, err := RealTillerIniter{}.installHelmClient(m.Mixin, "v2.16.1")
+	require.NoError(t, err)
+	assert.Equal(t, "/root/.helm/clients/v2.16.1/helm", path)
+	assert.Empty(t, m.TestContext.GetOutput(), "a cached client should not be downloaded")
+}
+
+func TestMixin_Init_UsesCompatibleClient(t *testing.T) {
+	h := NewTestMixin(t)
+
+	initer := NewMockTillerIniter()
+	initer.GetTillerStatus = func(m *Mixin) (TillerStatus, error) {
+		return TillerStatus{State: TillerReady, Version: "v2.16.1"}, nil
+	}
+	initer.InstallHelmClient = func(m *Mixin, version string) (string, error) {
+		return getCachedHelmClientPath(version), nil
+	}
+	h.Mixin.TillerIniter = initer
+
+	err := h.Init()
+	require.NoError(t, err)
+
+	assert.Equal(t, "v2.16.1", h.HelmClientVersion)
+	cmd := h.newHelmCommand("version")
+	assert.Contains(t, cmd.Args, "/root/.helm/clients/v2.16.1/helm", "helm commands should use the cached client")
+}
@@ -1,11 +1,11 @@
 package helm2
 
 import (
-	"os/exec"
 	"testing"
 
+	"get.porter.sh/mixin/helm2/pkg/kubernetes"
 	"get.porter.sh/porter/pkg/context"
-	"k8s.io/client-go/kubernetes"
+	k8s "k8s.io/client-go/kubernetes"
 	testclient "k8s.io/client-go/kubernetes/fake"
 )
 
@@ -14,51 +14,45 @@ const MockHelmClientVersion string = "v2.17.0"
 type TestMixin struct {
 	*Mixin
 	TestContext *context.TestContext
+	KubeClient  *testclient.Clientset
 }
 
 type testKubernetesFactory struct {
+	client *testclient.Clientset
 }
 
-func (t *testKubernetesFactory) GetClient(configPath string) (kubernetes.Interface, error) {
-	return testclient.NewSimpleClientset(), nil
+func (t *testKubernetesFactory) GetClient(cfg kubernetes.Config) (k8s.Interface, error) {
+	return t.client, nil
 }
 
 type MockTillerIniter struct {
-	GetTillerVersion   func(m *Mixin) (string, error)
-	SetupTillerRBAC    func(m *Mixin) error
-	RunRBACResourceCmd func(m *Mixin, cmd *exec.Cmd) error
-	InstallHelmClient  func(m *Mixin, version string) error
+	GetTillerStatus   func(m *Mixin) (TillerStatus, error)
+	SetupTillerRBAC   func(m *Mixin) error
+	InstallHelmClient func(m *Mixin, version string) (string, error)
 }
 
-func (t MockTillerIniter) getTillerVersion(m *Mixin) (string, error) {
-	return t.GetTillerVersion(m)
+func (t MockTillerIniter) getTillerStatus(m *Mixin) (TillerStatus, error) {
+	return t.GetTillerStatus(m)
 }
 
 func (t MockTillerIniter) setupTillerRBAC(m *Mixin) error {
 	return t.SetupTillerRBAC(m)
 }
 
-func (t MockTillerIniter) runRBACResourceCmd(m *Mixin, cmd *exec.Cmd) error {
-	return t.RunRBACResourceCmd(m, cmd)
-}
-
-func (t MockTillerIniter) installHelmClient(m *Mixin, version string) error {
+func (t MockTillerIniter) installHelmClient(m *Mixin, version string) (string, error) {
 	return t.InstallHelmClient(m, version)
 }
 
 func NewMockTillerIniter() MockTillerIniter {
 	return MockTillerIniter{
-		GetTillerVersion: func(m *Mixin) (string, error) {
-			return MockHelmClientVersion, nil
+		GetTillerStatus: func(m *Mixin) (TillerStatus, error) {
+			return TillerStatus{State: TillerReady, Version: MockHelmClientVersion}, nil
 		},
 		SetupTillerRBAC: func(m *Mixin) error {
 			return nil
 		},
-		RunRBACResourceCmd: func(m *Mixin, cmd *exec.Cmd) error {
-			return nil
-		},
-		InstallHelmClient: func(m *Mixin, version string) error {
-			return nil
+		InstallHelmClient: func(m *Mixin, version string) (string, error) {
+			return "helm", nil
 		},
 	}
 }
@@ -68,11 +62,13 @@ func NewTestMixin(t *testing.T) *TestMixin {
 	c := context.NewTestContext(t)
 	m := New()
 	m.Context = c.Context
-	m.ClientFactory = &testKubernetesFactory{}
+	kubeClient := testclient.NewSimpleClientset()
+	m.ClientFactory = &testKubernetesFactory{client: kubeClient}
 	m.TillerIniter = NewMockTillerIniter()
 	m.HelmClientVersion = MockHelmClientVersion
 	return &TestMixin{
 		Mixin:       m,
 		TestContext: c,
+		KubeClient:  kubeClient,
 	}
 }
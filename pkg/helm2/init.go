@@ -1,183 +1,219 @@
 package helm2
 
 import (
-	"bytes"
 	"fmt"
-	"io"
-	"net/http"
-	"os"
-	"os/exec"
-	"path/filepath"
-	"regexp"
-	"strings"
-
-	"github.com/mholt/archiver"
+	"net/url"
+
 	"github.com/pkg/errors"
 )
 
-const tillerNotReadyErr string = "could not find a ready tiller pod"
-
-const tillerNotFoundErr string = "could not find tiller"
-
 // TillerIniter is an interface for methods associated with Tiller interactions
 type TillerIniter interface {
 	setupTillerRBAC(m *Mixin) error
-	getTillerVersion(m *Mixin) (string, error)
-	runRBACResourceCmd(m *Mixin, cmd *exec.Cmd) error
-	installHelmClient(m *Mixin, version string) error
+	getTillerStatus(m *Mixin) (TillerStatus, error)
+	installHelmClient(m *Mixin, version string) (string, error)
 }
 
 // RealTillerIniter implements the TillerIniter interface, in REAL life
 type RealTillerIniter struct{}
 
-// Init inits the Helm server (Tiller) if not running, else, ensures the
-// Helm client matches the installed Tiller version
+// Init inits the Helm server (Tiller) if not running, else, resolves any
+// difference between the Helm client and Tiller versions with the version policy
 func (m *Mixin) Init() error {
 	ti := m.TillerIniter
 
-	tillerVersion, err := ti.getTillerVersion(m)
+	err := m.loadTLSCertificates()
+	if err != nil {
+		return errors.Wrap(err, "failed to load the Tiller certificates")
+	}
+
+	status, err := ti.getTillerStatus(m)
 	if err != nil {
-		switch errMsg := err.Error(); {
-		case strings.Contains(errMsg, tillerNotReadyErr) || strings.Contains(errMsg, tillerNotFoundErr):
+		return errors.Wrap(err, "unable to communicate with Tiller")
+	}
+
+	manage := m.Config.Tiller.GetManage()
+	if manage == tillerManageVerifyOnly {
+		return m.verifyTiller(status)
+	}
+
+	switch status.State {
+	case TillerAbsent, TillerNotReady:
+		if manage == tillerManageFalse {
+			return unmanagedTillerError(status, m.Tiller.GetTillerNamespace(), manage)
+		}
+
+		if status.State == TillerAbsent {
+			fmt.Fprintln(m.Out, "Tiller is not installed; attempting to init.")
+		} else {
 			fmt.Fprintln(m.Out, "Tiller is not ready; attempting to init.")
+		}
 
+		fmt.Fprintln(m.Out, m.Config.RBAC.Describe(m.Tiller))
+		if m.Config.RBAC.GetMode() != rbacModeNone {
 			err := ti.setupTillerRBAC(m)
 			if err != nil {
 				return errors.Wrap(err, "failed to setup RBAC for Tiller")
 			}
+		}
 
-			initCmd := m.NewCommand("helm", "init", "--service-account=tiller-deploy", "--upgrade", "--wait")
-			prettyCmd := fmt.Sprintf("%s %s", initCmd.Path, strings.Join(initCmd.Args, " "))
-
-			initCmd.Stdout = m.Out
-			initCmd.Stderr = m.Err
-
-			err = initCmd.Start()
-			if err != nil {
-				return errors.Wrapf(err, "could not execute command, %s", prettyCmd)
-			}
-			err = initCmd.Wait()
-			if err != nil {
-				return errors.Wrap(err, "unable to init Tiller")
-			}
-		default:
-			return errors.Wrap(err, "unable to communicate with Tiller")
+		err = m.runHelmInit()
+		if err != nil {
+			return err
+		}
+	case TillerReady:
+		if status.Version == "" {
+			fmt.Fprintf(m.Out, "Unable to determine the Tiller version from image %s; using client version %s.\n",
+				status.Image, m.HelmClientVersion)
+			return nil
 		}
-	} else {
-		if m.HelmClientVersion != tillerVersion {
-			fmt.Fprintf(m.Out, "Tiller version (%s) does not match client version (%s); downloading a compatible client.\n",
-				tillerVersion, m.HelmClientVersion)
 
-			err := ti.installHelmClient(m, tillerVersion)
-			if err != nil {
-				return errors.Wrap(err, "unable to install a compatible helm client")
-			}
+		if m.HelmClientVersion != status.Version {
+			return m.reconcileTillerVersion(status)
 		}
 	}
 	return nil
 }
 
-func (r RealTillerIniter) setupTillerRBAC(m *Mixin) error {
-	cmd := m.NewCommand("kubectl", "create", "serviceaccount", "-n", "kube-system", "tiller-deploy")
-	err := r.runRBACResourceCmd(m, cmd)
-	if err != nil {
-		return err
+// reconcileTillerVersion resolves a difference between the versions of a ready
+// Tiller and the helm client, according to the version policy.
+func (m *Mixin) reconcileTillerVersion(status TillerStatus) error {
+	policy := m.Config.Tiller.GetVersionPolicy()
+
+	// The tags may be spelled differently, e.g. with and without a leading v. Tiller
+	// images with a tag that isn't semver are matched by installing a client for the tag.
+	same, err := validate(status.Version, "= "+m.HelmClientVersion)
+	if err != nil && policy != versionPolicyMatchTiller {
+		return errors.Wrap(err, "unable to compare the Tiller and client versions")
+	}
+	if same {
+		return nil
 	}
 
-	cmd = m.NewCommand("kubectl", "create", "clusterrolebinding", "tiller-deploy",
-		"--clusterrole", "cluster-admin", "--serviceaccount", "kube-system:tiller-deploy")
-	return r.runRBACResourceCmd(m, cmd)
-}
+	if policy == versionPolicyMatchTiller {
+		fmt.Fprintf(m.Out, "Tiller version (%s) does not match client version (%s); using a compatible client.\n",
+			status.Version, m.HelmClientVersion)
 
-func (r RealTillerIniter) runRBACResourceCmd(m *Mixin, cmd *exec.Cmd) error {
-	var stderr bytes.Buffer
+		clientPath, err := m.TillerIniter.installHelmClient(m, status.Version)
+		if err != nil {
+			return errors.Wrap(err, "unable to install a compatible helm client")
+		}
+		m.HelmClientVersion = status.Version
+		m.HelmClientPath = clientPath
+		return nil
+	}
 
-	prettyCmd := fmt.Sprintf("%s %s", cmd.Path, strings.Join(cmd.Args, " "))
-	cmd.Stdout = m.Out
-	// We'll be checking stderr to determine whether or not to error out or ignore
-	cmd.Stderr = &stderr
+	if policy == versionPolicyFail {
+		return errors.Errorf("Tiller version (%s) in namespace %s does not match client version (%s) and the tiller versionPolicy is %s",
+			status.Version, m.Tiller.GetTillerNamespace(), m.HelmClientVersion, versionPolicyFail)
+	}
 
-	err := cmd.Start()
+	older, err := validate(status.Version, "< "+m.HelmClientVersion)
 	if err != nil {
-		return errors.Wrapf(err, "could not execute command, %s", prettyCmd)
+		return errors.Wrap(err, "unable to compare the Tiller and client versions")
 	}
-	err = cmd.Wait()
-	if err != nil {
-		// Only return the error if other than a pre-existence error
-		if !strings.Contains(stderr.String(), "already exists") {
-			return errors.Wrapf(err,
-				"unable to create RBAC resource: %s", stderr.String())
-		}
+	if !older {
+		return errors.Errorf("Tiller version (%s) in namespace %s is newer than client version (%s); the tiller versionPolicy %s will not downgrade Tiller",
+			status.Version, m.Tiller.GetTillerNamespace(), m.HelmClientVersion, versionPolicyUpgradeTiller)
 	}
-	return nil
-}
 
-func (r RealTillerIniter) getTillerVersion(m *Mixin) (string, error) {
-	var stderr bytes.Buffer
-
-	cmd := m.NewCommand("helm", "version", "--server")
-	cmd.Stderr = &stderr
+	fmt.Fprintf(m.Out, "Tiller version (%s) is older than client version (%s); upgrading Tiller.\n",
+		status.Version, m.HelmClientVersion)
+	return m.runHelmInit("--force-upgrade")
+}
 
-	outputBytes, err := cmd.Output()
+// runHelmInit installs or upgrades Tiller to the version of the helm client.
+func (m *Mixin) runHelmInit(args ...string) error {
+	tlsFlags, err := m.tillerTLSFlags()
 	if err != nil {
-		return "", errors.Wrapf(err, "unable to determine Helm's server version: %s", stderr.String())
+		return errors.Wrap(err, "failed to setup TLS for Tiller")
 	}
-	re := regexp.MustCompile(`v[0-9]*\.[0-9]*\.[0-9]*`)
-	version := re.FindString(string(outputBytes))
 
-	return version, nil
-}
+	initArgs := []string{"init", "--service-account=" + m.Tiller.GetServiceAccount(), "--upgrade"}
+	initArgs = append(initArgs, args...)
+	initArgs = append(initArgs, m.Config.Tiller.InitFlags()...)
+	initArgs = append(initArgs, m.Config.InitRepoFlags()...)
+	initArgs = append(initArgs, "--wait")
+	initCmd := m.newHelmCommand(append(initArgs, tlsFlags...)...)
+	prettyCmd := m.prettyCommand(initCmd)
 
-func (r RealTillerIniter) installHelmClient(m *Mixin, version string) error {
-	helmArchive := fmt.Sprintf(helmArchiveTmpl, version)
-	url := fmt.Sprintf(helmDownloadURLTmpl, helmArchive)
+	initCmd.Stdout = m.Out
+	initCmd.Stderr = m.Err
 
-	// Fetch archive from url
-	req, err := http.NewRequest("GET", url, nil)
+	err = initCmd.Start()
 	if err != nil {
-		return errors.Wrap(err, "failed to construct GET request for fetching helm client binary")
+		return errors.Wrapf(err, "could not execute command, %s", prettyCmd)
 	}
-	res, err := http.DefaultClient.Do(req)
+	err = initCmd.Wait()
 	if err != nil {
-		return errors.Wrapf(err, "failed to download helm client binary via url: %s", url)
+		return errors.Wrap(err, "unable to init Tiller")
 	}
-	defer res.Body.Close()
+	return nil
+}
 
-	// Create a temp dir
-	tmpDir, err := m.FileSystem.TempDir("", "tmp")
-	if err != nil {
-		return errors.Wrap(err, "unable to create a temporary directory for downloading the helm client binary")
+// ValidateInitRepos checks that the stable repository is either replaced or skipped, not both.
+func (c RuntimeConfig) ValidateInitRepos() error {
+	if c.StableRepoURL == "" {
+		return nil
+	}
+	if c.SkipRepos {
+		return errors.New("stableRepoURL may not be set when skipRepos is true")
 	}
-	defer os.RemoveAll(tmpDir)
+	u, err := url.Parse(c.StableRepoURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return errors.Errorf("invalid stableRepoURL %q, it must be an http or https URL", c.StableRepoURL)
+	}
+	return nil
+}
 
-	// Create the local archive
-	archiveFile, err := m.FileSystem.Create(filepath.Join(tmpDir, helmArchive))
-	if err != nil {
-		return errors.Wrap(err, "unable to create a local file for the helm client binary")
+// InitRepoFlags returns the helm init flags that select the repositories it adds.
+func (c RuntimeConfig) InitRepoFlags() []string {
+	if c.SkipRepos {
+		return []string{"--skip-repos"}
 	}
+	if c.StableRepoURL != "" {
+		return []string{"--stable-repo-url", c.StableRepoURL}
+	}
+	return nil
+}
 
-	// Copy response body to local archive
-	_, err = io.Copy(archiveFile, res.Body)
-	if err != nil {
-		return errors.Wrap(err, "unable to copy the helm client binary to the local archive file")
+// verifyTiller checks that Tiller is ready and compatible with the helm client,
+// without changing the cluster or the client.
+func (m *Mixin) verifyTiller(status TillerStatus) error {
+	namespace := m.Tiller.GetTillerNamespace()
+	if status.State != TillerReady {
+		return unmanagedTillerError(status, namespace, tillerManageVerifyOnly)
+	}
+	if status.Version == "" {
+		return errors.Errorf("unable to verify the version of Tiller in namespace %s from image %s", namespace, status.Image)
 	}
 
-	// Create a dir for unarchived contents
-	unarchivedDir, err := m.FileSystem.TempDir(tmpDir, "helm")
+	ok, err := isTillerCompatible(m.HelmClientVersion, status.Version)
 	if err != nil {
-		return errors.Wrap(err, "unable to create a temporary directory for the unarchived helm client download contents")
+		return err
 	}
+	if !ok {
+		return errors.Errorf("Tiller version (%s) in namespace %s is not compatible with client version (%s)",
+			status.Version, namespace, m.HelmClientVersion)
+	}
+
+	fmt.Fprintf(m.Out, "Verified that Tiller %s in namespace %s is ready and compatible with client version %s.\n",
+		status.Version, namespace, m.HelmClientVersion)
+	return nil
+}
 
-	err = archiver.Unarchive(archiveFile.Name(), unarchivedDir)
+func (r RealTillerIniter) setupTillerRBAC(m *Mixin) error {
+	client, err := m.getKubernetesClient()
 	if err != nil {
-		return errors.Wrap(err, "unable to unarchive the helm client download")
+		return errors.Wrap(err, "couldn't get kubernetes client")
 	}
+	return m.reconcileTillerRBAC(client)
+}
 
-	// Move the helm binary into the appropriate location
-	binPath := "/usr/local/bin/helm"
-	err = m.FileSystem.Rename(fmt.Sprintf("%s/linux-amd64/helm", unarchivedDir), binPath)
+func (r RealTillerIniter) getTillerStatus(m *Mixin) (TillerStatus, error) {
+	client, err := m.getKubernetesClient()
 	if err != nil {
-		return errors.Wrapf(err, "unable to install the helm client binary to %q", binPath)
+		return TillerStatus{}, errors.Wrap(err, "couldn't get kubernetes client")
 	}
-	return nil
+	return getTillerStatus(client, m.Tiller.GetTillerNamespace())
 }
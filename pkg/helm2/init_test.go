@@ -15,8 +15,8 @@ func TestMixin_Init_TillerNotReady(t *testing.T) {
 	h := NewTestMixin(t)
 
 	initer := NewMockTillerIniter()
-	initer.GetTillerVersion = func(m *Mixin) (string, error) {
-		return "", errors.New(tillerNotReadyErr)
+	initer.GetTillerStatus = func(m *Mixin) (TillerStatus, error) {
+		return TillerStatus{State: TillerNotReady}, nil
 	}
 	h.Mixin.TillerIniter = initer
 
@@ -24,7 +24,8 @@ func TestMixin_Init_TillerNotReady(t *testing.T) {
 	require.NoError(t, err)
 
 	gotOutput := h.TestContext.GetOutput()
-	wantOutput := "Tiller is not ready; attempting to init.\n"
+	wantOutput := "Tiller is not ready; attempting to init.\n" +
+		"RBAC mode clusterAdmin: binding service account kube-system:tiller-deploy to ClusterRole cluster-admin\n"
 	require.Equal(t, wantOutput, gotOutput)
 }
 
@@ -33,8 +34,8 @@ func TestMixin_Init_FailedRBACSetup(t *testing.T) {
 	h := NewTestMixin(t)
 
 	initer := NewMockTillerIniter()
-	initer.GetTillerVersion = func(m *Mixin) (string, error) {
-		return "", errors.New(tillerNotReadyErr)
+	initer.GetTillerStatus = func(m *Mixin) (TillerStatus, error) {
+		return TillerStatus{State: TillerNotReady}, nil
 	}
 	initer.SetupTillerRBAC = func(m *Mixin) error {
 		return errors.New("failed to setup RBAC")
@@ -46,7 +47,32 @@ func TestMixin_Init_FailedRBACSetup(t *testing.T) {
 	require.EqualError(t, err, "failed to setup RBAC for Tiller: failed to setup RBAC")
 
 	gotOutput := h.TestContext.GetOutput()
-	wantOutput := "Tiller is not ready; attempting to init.\n"
+	wantOutput := "Tiller is not ready; attempting to init.\n" +
+		"RBAC mode clusterAdmin: binding service account kube-system:tiller-deploy to ClusterRole cluster-admin\n"
+	require.Equal(t, wantOutput, gotOutput)
+}
+
+func TestMixin_Init_SkipRBACSetup(t *testing.T) {
+	os.Setenv(test.ExpectedCommandEnv, "helm init --service-account=tiller-deploy --upgrade --wait")
+	defer os.Unsetenv(test.ExpectedCommandEnv)
+	h := NewTestMixin(t)
+	h.Config.RBAC = RBACConfig{Mode: rbacModeNone}
+
+	initer := NewMockTillerIniter()
+	initer.GetTillerStatus = func(m *Mixin) (TillerStatus, error) {
+		return TillerStatus{State: TillerNotReady}, nil
+	}
+	initer.SetupTillerRBAC = func(m *Mixin) error {
+		return errors.New("RBAC setup should have been skipped")
+	}
+	h.Mixin.TillerIniter = initer
+
+	err := h.Init()
+	require.NoError(t, err)
+
+	gotOutput := h.TestContext.GetOutput()
+	wantOutput := "Tiller is not ready; attempting to init.\n" +
+		"RBAC mode none: skipping RBAC setup for service account kube-system:tiller-deploy\n"
 	require.Equal(t, wantOutput, gotOutput)
 }
 
@@ -54,28 +80,30 @@ func TestMixin_Init_MismatchedVersion(t *testing.T) {
 	h := NewTestMixin(t)
 
 	initer := NewMockTillerIniter()
-	initer.GetTillerVersion = func(m *Mixin) (string, error) {
-		return "mismatchedVersion", nil
+	initer.GetTillerStatus = func(m *Mixin) (TillerStatus, error) {
+		return TillerStatus{State: TillerReady, Version: "mismatchedVersion"}, nil
 	}
 	h.Mixin.TillerIniter = initer
+	clientVersion := h.HelmClientVersion
 
 	err := h.Init()
 	require.NoError(t, err)
 
 	gotOutput := h.TestContext.GetOutput()
-	wantOutput := fmt.Sprintf("Tiller version (mismatchedVersion) does not match client version (%s); downloading a compatible client.\n", h.HelmClientVersion)
+	wantOutput := fmt.Sprintf("Tiller version (mismatchedVersion) does not match client version (%s); using a compatible client.\n", clientVersion)
 	require.Equal(t, wantOutput, gotOutput)
+	require.Equal(t, "mismatchedVersion", h.HelmClientVersion, "the client that matches Tiller should be used")
 }
 
 func TestMixin_Init_FailedClientInstall(t *testing.T) {
 	h := NewTestMixin(t)
 
 	initer := NewMockTillerIniter()
-	initer.GetTillerVersion = func(m *Mixin) (string, error) {
-		return "mismatchedVersion", nil
+	initer.GetTillerStatus = func(m *Mixin) (TillerStatus, error) {
+		return TillerStatus{State: TillerReady, Version: "mismatchedVersion"}, nil
 	}
-	initer.InstallHelmClient = func(m *Mixin, version string) error {
-		return errors.New("failed to install helm client")
+	initer.InstallHelmClient = func(m *Mixin, version string) (string, error) {
+		return "", errors.New("failed to install helm client")
 	}
 	h.Mixin.TillerIniter = initer
 
@@ -83,6 +111,265 @@ func TestMixin_Init_FailedClientInstall(t *testing.T) {
 	require.EqualError(t, err, "unable to install a compatible helm client: failed to install helm client")
 
 	gotOutput := h.TestContext.GetOutput()
-	wantOutput := fmt.Sprintf("Tiller version (mismatchedVersion) does not match client version (%s); downloading a compatible client.\n", h.HelmClientVersion)
+	wantOutput := fmt.Sprintf("Tiller version (mismatchedVersion) does not match client version (%s); using a compatible client.\n", h.HelmClientVersion)
+	require.Equal(t, wantOutput, gotOutput)
+}
+
+func TestMixin_Init_TillerNamespace(t *testing.T) {
+	os.Setenv(test.ExpectedCommandEnv, "helm init --service-account=tenant-tiller --upgrade --wait --tiller-namespace tenant-a")
+	defer os.Unsetenv(test.ExpectedCommandEnv)
+	h := NewTestMixin(t)
+	h.Tiller = TillerSettings{TillerNamespace: "tenant-a", ServiceAccount: "tenant-tiller"}
+
+	initer := NewMockTillerIniter()
+	initer.GetTillerStatus = func(m *Mixin) (TillerStatus, error) {
+		return TillerStatus{State: TillerAbsent}, nil
+	}
+	h.Mixin.TillerIniter = initer
+
+	err := h.Init()
+	require.NoError(t, err)
+}
+
+func TestTillerBindingName(t *testing.T) {
+	require.Equal(t, "tiller-deploy", tillerBindingName(TillerSettings{}))
+	require.Equal(t, "tenant-a-tiller-deploy", tillerBindingName(TillerSettings{TillerNamespace: "tenant-a"}))
+	require.Equal(t, "tenant-a-tenant-tiller", tillerBindingName(TillerSettings{TillerNamespace: "tenant-a", ServiceAccount: "tenant-tiller"}))
+}
+
+func TestMixin_Init_UnknownTillerVersion(t *testing.T) {
+	h := NewTestMixin(t)
+
+	initer := NewMockTillerIniter()
+	initer.GetTillerStatus = func(m *Mixin) (TillerStatus, error) {
+		return TillerStatus{State: TillerReady, Image: "registry.example.com/tiller@sha256:abc123"}, nil
+	}
+	initer.InstallHelmClient = func(m *Mixin, version string) (string, error) {
+		return "", errors.New("the client should not be replaced")
+	}
+	h.Mixin.TillerIniter = initer
+
+	err := h.Init()
+	require.NoError(t, err)
+
+	gotOutput := h.TestContext.GetOutput()
+	wantOutput := fmt.Sprintf("Unable to determine the Tiller version from image registry.example.com/tiller@sha256:abc123; using client version %s.\n", h.HelmClientVersion)
 	require.Equal(t, wantOutput, gotOutput)
 }
+
+func TestMixin_Init_UnmanagedTiller(t *testing.T) {
+	h := NewTestMixin(t)
+	h.Config.Tiller.Manage = tillerManageFalse
+
+	initer := NewMockTillerIniter()
+	initer.GetTillerStatus = func(m *Mixin) (TillerStatus, error) {
+		return TillerStatus{State: TillerAbsent}, nil
+	}
+	initer.SetupTillerRBAC = func(m *Mixin) error {
+		return errors.New("RBAC should not be modified")
+	}
+	h.Mixin.TillerIniter = initer
+
+	err := h.Init()
+	require.EqualError(t, err, "Tiller is absent in namespace kube-system and the mixin is configured with tiller.manage: false, so it will not install it. "+
+		"Install Tiller in that namespace, check that its pods are ready, or remove the setting to let the mixin manage Tiller")
+}
+
+func TestMixin_Init_VerifyOnly(t *testing.T) {
+	testcases := []struct {
+		name   string
+		status TillerStatus
+		output string
+		error  string
+	}{
+		{
+			name:   "compatible",
+			status: TillerStatus{State: TillerReady, Version: "v2.17.1"},
+			output: "Verified that Tiller v2.17.1 in namespace kube-system is ready and compatible with client version v2.17.0.\n",
+		},
+		{
+			name:   "incompatible",
+			status: TillerStatus{State: TillerReady, Version: "v2.16.1"},
+			error:  "Tiller version (v2.16.1) in namespace kube-system is not compatible with client version (v2.17.0)",
+		},
+		{
+			name:   "not ready",
+			status: TillerStatus{State: TillerNotReady, Version: "v2.17.0"},
+			error: "Tiller is not-ready in namespace kube-system and the mixin is configured with tiller.manage: verify-only, so it will not install it. " +
+				"Install Tiller in that namespace, check that its pods are ready, or remove the setting to let the mixin manage Tiller",
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			h := NewTestMixin(t)
+			h.Config.Tiller.Manage = tillerManageVerifyOnly
+
+			initer := NewMockTillerIniter()
+			initer.GetTillerStatus = func(m *Mixin) (TillerStatus, error) {
+				return tc.status, nil
+			}
+			initer.InstallHelmClient = func(m *Mixin, version string) (string, error) {
+				return "", errors.New("the client should not be replaced")
+			}
+			h.Mixin.TillerIniter = initer
+
+			err := h.Init()
+			if tc.error != "" {
+				require.EqualError(t, err, tc.error)
+			} else {
+				require.NoError(t, err)
+				require.Equal(t, tc.output, h.TestContext.GetOutput())
+			}
+		})
+	}
+}
+
+func TestMixin_Init_VersionPolicyUpgradeTiller(t *testing.T) {
+	os.Setenv(test.ExpectedCommandEnv, "helm init --service-account=tiller-deploy --upgrade --force-upgrade --wait")
+	defer os.Unsetenv(test.ExpectedCommandEnv)
+	h := NewTestMixin(t)
+	h.Config.Tiller.VersionPolicy = versionPolicyUpgradeTiller
+
+	initer := NewMockTillerIniter()
+	initer.GetTillerStatus = func(m *Mixin) (TillerStatus, error) {
+		return TillerStatus{State: TillerReady, Version: "v2.16.1"}, nil
+	}
+	initer.InstallHelmClient = func(m *Mixin, version string) (string, error) {
+		return "", errors.New("the client should not be replaced")
+	}
+	h.Mixin.TillerIniter = initer
+
+	err := h.Init()
+	require.NoError(t, err)
+
+	gotOutput := h.TestContext.GetOutput()
+	wantOutput := fmt.Sprintf("Tiller version (v2.16.1) is older than client version (%s); upgrading Tiller.\n", MockHelmClientVersion)
+	require.Equal(t, wantOutput, gotOutput)
+	require.Equal(t, MockHelmClientVersion, h.HelmClientVersion)
+}
+
+func TestMixin_Init_VersionPolicyUpgradeTillerNewerTiller(t *testing.T) {
+	h := NewTestMixin(t)
+	h.Config.Tiller.VersionPolicy = versionPolicyUpgradeTiller
+
+	initer := NewMockTillerIniter()
+	initer.GetTillerStatus = func(m *Mixin) (TillerStatus, error) {
+		return TillerStatus{State: TillerReady, Version: "v2.17.1"}, nil
+	}
+	h.Mixin.TillerIniter = initer
+
+	err := h.Init()
+	require.EqualError(t, err, "Tiller version (v2.17.1) in namespace kube-system is newer than client version (v2.17.0); "+
+		"the tiller versionPolicy upgradeTiller will not downgrade Tiller")
+}
+
+func TestMixin_Init_VersionPolicyFail(t *testing.T) {
+	h := NewTestMixin(t)
+	h.Config.Tiller.VersionPolicy = versionPolicyFail
+
+	initer := NewMockTillerIniter()
+	initer.GetTillerStatus = func(m *Mixin) (TillerStatus, error) {
+		return TillerStatus{State: TillerReady, Version: "v2.16.1"}, nil
+	}
+	h.Mixin.TillerIniter = initer
+
+	err := h.Init()
+	require.EqualError(t, err, "Tiller version (v2.16.1) in namespace kube-system does not match client version (v2.17.0) "+
+		"and the tiller versionPolicy is fail")
+}
+
+func TestMixin_Init_VersionPolicyFailEquivalentVersion(t *testing.T) {
+	h := NewTestMixin(t)
+	h.Config.Tiller.VersionPolicy = versionPolicyFail
+
+	initer := NewMockTillerIniter()
+	initer.GetTillerStatus = func(m *Mixin) (TillerStatus, error) {
+		return TillerStatus{State: TillerReady, Version: "2.17.0"}, nil
+	}
+	h.Mixin.TillerIniter = initer
+
+	err := h.Init()
+	require.NoError(t, err, "versions that differ only by the leading v should match")
+	require.Empty(t, h.TestContext.GetOutput())
+}
+
+func TestMixin_Init_MatchTillerEquivalentVersion(t *testing.T) {
+	h := NewTestMixin(t)
+	h.HelmClientVersion = "2.16.1"
+
+	initer := NewMockTillerIniter()
+	initer.GetTillerStatus = func(m *Mixin) (TillerStatus, error) {
+		return TillerStatus{State: TillerReady, Version: "v2.16.1"}, nil
+	}
+	initer.InstallHelmClient = func(m *Mixin, version string) (string, error) {
+		return "", errors.New("the client should not be replaced")
+	}
+	h.Mixin.TillerIniter = initer
+
+	err := h.Init()
+	require.NoError(t, err, "versions that differ only by the leading v should match")
+	require.Empty(t, h.TestContext.GetOutput())
+	require.Equal(t, "2.16.1", h.HelmClientVersion)
+}
+
+func TestMixin_Init_TillerInitSettings(t *testing.T) {
+	os.Setenv(test.ExpectedCommandEnv, "helm init --service-account=tiller-deploy --upgrade "+
+		"--history-max 10 --override storage=secret --tiller-image registry.example.com/tiller:v2.17.0 --wait")
+	defer os.Unsetenv(test.ExpectedCommandEnv)
+	h := NewTestMixin(t)
+	h.Config.Tiller = TillerConfig{HistoryMax: 10, Storage: "secret", Image: "registry.example.com/tiller:v2.17.0"}
+	h.Config.RBAC.Mode = rbacModeNone
+
+	initer := NewMockTillerIniter()
+	initer.GetTillerStatus = func(m *Mixin) (TillerStatus, error) {
+		return TillerStatus{State: TillerAbsent}, nil
+	}
+	h.Mixin.TillerIniter = initer
+
+	err := h.Init()
+	require.NoError(t, err)
+}
+
+func TestMixin_Init_StableRepoURL(t *testing.T) {
+	os.Setenv(test.ExpectedCommandEnv, "helm init --service-account=tiller-deploy --upgrade "+
+		"--stable-repo-url https://charts.helm.sh/stable --wait")
+	defer os.Unsetenv(test.ExpectedCommandEnv)
+	h := NewTestMixin(t)
+	h.Config.StableRepoURL = "https://charts.helm.sh/stable"
+	h.Config.RBAC.Mode = rbacModeNone
+
+	initer := NewMockTillerIniter()
+	initer.GetTillerStatus = func(m *Mixin) (TillerStatus, error) {
+		return TillerStatus{State: TillerAbsent}, nil
+	}
+	h.Mixin.TillerIniter = initer
+
+	err := h.Init()
+	require.NoError(t, err)
+}
+
+func TestRuntimeConfig_InitRepoFlags(t *testing.T) {
+	testcases := map[string]struct {
+		cfg       RuntimeConfig
+		wantFlags []string
+		wantErr   string
+	}{
+		"default":         {cfg: RuntimeConfig{}},
+		"stable repo url": {cfg: RuntimeConfig{StableRepoURL: "https://charts.helm.sh/stable"}, wantFlags: []string{"--stable-repo-url", "https://charts.helm.sh/stable"}},
+		"skip repos":      {cfg: RuntimeConfig{SkipRepos: true}, wantFlags: []string{"--skip-repos"}},
+		"both":            {cfg: RuntimeConfig{StableRepoURL: "https://charts.helm.sh/stable", SkipRepos: true}, wantErr: "stableRepoURL may not be set when skipRepos is true"},
+		"invalid url":     {cfg: RuntimeConfig{StableRepoURL: "charts.helm.sh/stable"}, wantErr: `invalid stableRepoURL "charts.helm.sh/stable", it must be an http or https URL`},
+	}
+	for name, tc := range testcases {
+		t.Run(name, func(t *testing.T) {
+			err := tc.cfg.ValidateInitRepos()
+			if tc.wantErr != "" {
+				require.EqualError(t, err, tc.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.wantFlags, tc.cfg.InitRepoFlags())
+		})
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
+	"regexp"
 	"testing"
 
 	"get.porter.sh/porter/pkg/test"
@@ -44,6 +45,8 @@ func TestMixin_UnmarshalInstallStep(t *testing.T) {
 	assert.Equal(t, true, step.Replace)
 	assert.Equal(t, map[string]string{"mysqlDatabase": "mydb", "mysqlUser": "myuser",
 		"livenessProbe.initialDelaySeconds": "30", "persistence.enabled": "true"}, step.Set)
+	assert.Equal(t, map[string]string{"imageTag": "0123"}, step.SetString)
+	assert.Equal(t, map[string]string{`configurationFiles.mysql\.cnf`: "files/mysql.cnf"}, step.SetFile)
 }
 
 func TestMixin_Install(t *testing.T) {
@@ -124,6 +127,38 @@ func TestMixin_Install(t *testing.T) {
 				},
 			},
 		},
+		{
+			expectedCommand: fmt.Sprintf(`helm install --name %s %s --kube-context %s --namespace %s --version %s %s %s`, name, chart, "mycontext", namespace, version, baseValues, baseSetArgs),
+			installStep: InstallStep{
+				InstallArguments: InstallArguments{
+					Step:               Step{Description: "Install Foo"},
+					KubernetesSettings: KubernetesSettings{KubeConfig: "/cnab/app/kubeconfig", KubeContext: "mycontext"},
+					Namespace:          namespace,
+					Name:               name,
+					Chart:              chart,
+					Version:            version,
+					Set:                setArgs,
+					Values:             values,
+				},
+			},
+		},
+		{
+			expectedCommand: fmt.Sprintf(`%s %s %s %s`, baseInstall, baseValues, baseSetArgs,
+				`--set-string build=0123 --set-file config=/cnab/app/files/config.toml`),
+			installStep: InstallStep{
+				InstallArguments: InstallArguments{
+					Step:      Step{Description: "Install Foo"},
+					Namespace: namespace,
+					Name:      name,
+					Chart:     chart,
+					Version:   version,
+					Set:       setArgs,
+					SetString: map[string]string{"build": "0123"},
+					SetFile:   map[string]string{"config": "files/config.toml"},
+					Values:    values,
+				},
+			},
+		},
 	}
 
 	defer os.Unsetenv(test.ExpectedCommandEnv)
@@ -143,3 +178,21 @@ func TestMixin_Install(t *testing.T) {
 		})
 	}
 }
+
+func TestMixin_Install_InlineValues(t *testing.T) {
+	h := NewTestMixin(t)
+	b, err := ioutil.ReadFile("testdata/install-input-with-inline-values.yaml")
+	require.NoError(t, err)
+	h.In = bytes.NewReader(b)
+
+	err = h.Install()
+	require.NoError(t, err)
+
+	gotOutput := h.TestContext.GetOutput()
+	matches := regexp.MustCompile(`helm install --name mysql stable/mysql --version 0.10.2 --values /cnab/app/values.yaml --values (\S+) --set mysqlUser=myuser`).
+		FindStringSubmatch(gotOutput)
+	require.Len(t, matches, 2, "the inline values should be passed after the values files, and before set: %s", gotOutput)
+	exists, err := h.FileSystem.Exists(matches[1])
+	require.NoError(t, err)
+	assert.False(t, exists, "the inline values file should be removed after the install")
+}
@@ -0,0 +1,79 @@
+package helm2
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/Masterminds/semver"
+	"github.com/pkg/errors"
+)
+
+// kubectlVersionConstraint represents the semver constraint for the kubectl version
+const kubectlVersionConstraint string = "^v1.x"
+
+// fullKubectlVersion matches a complete kubectl release, such as v1.16.3. Partial versions,
+// such as 1.16, are accepted by semver but are not in the kubectl release URLs.
+var fullKubectlVersion = regexp.MustCompile(`^v?[0-9]+\.[0-9]+\.[0-9]+(-[0-9A-Za-z.-]+)?$`)
+
+// helmKubernetesVersions is the minor version of Kubernetes that each minor
+// version of helm 2 was built and tested against.
+var helmKubernetesVersions = map[int64]int64{
+	0: 4, 1: 4, 2: 5, 3: 5, 4: 6, 5: 6, 6: 7, 7: 8, 8: 9,
+	9: 10, 10: 10, 11: 11, 12: 12, 13: 13, 14: 14, 15: 15, 16: 16, 17: 16,
+}
+
+// InstallKubectl returns whether kubectl is installed in the invocation image, defaulting to true.
+func (c RuntimeConfig) InstallKubectl() bool {
+	return c.Kubectl == nil || *c.Kubectl
+}
+
+// normalizeKubectlVersion adds the leading v that kubectl release URLs require.
+func normalizeKubectlVersion(version string) string {
+	if strings.HasPrefix(version, "v") {
+		return version
+	}
+	return "v" + version
+}
+
+// validateKubectlVersion checks that the kubectl version is a release of kubectl
+// 1.x, and that it is within one minor version of the Kubernetes version that
+// the helm client supports, which is the skew supported by kubectl.
+func validateKubectlVersion(kubectlVersion, helmVersion string) error {
+	_, err := semver.NewVersion(kubectlVersion)
+	if err != nil {
+		return errors.Wrapf(err, "supplied kubectlVersion %q cannot be parsed as semver", kubectlVersion)
+	}
+	if !fullKubectlVersion.MatchString(kubectlVersion) {
+		return errors.Errorf("supplied kubectlVersion %q must be a full version, such as %s", kubectlVersion, kubeVersion)
+	}
+	ok, err := validate(kubectlVersion, kubectlVersionConstraint)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return errors.Errorf("supplied kubectlVersion %q does not meet semver constraint %q",
+			kubectlVersion, kubectlVersionConstraint)
+	}
+
+	h, err := semver.NewVersion(helmVersion)
+	if err != nil {
+		return errors.Wrapf(err, "supplied client version %q cannot be parsed as semver", helmVersion)
+	}
+	kubeMinor, ok := helmKubernetesVersions[h.Minor()]
+	if !ok {
+		// Newer helm releases are not known, there is nothing to check against
+		return nil
+	}
+
+	skew := fmt.Sprintf(">= 1.%d, < 1.%d", kubeMinor-1, kubeMinor+2)
+	ok, err = validate(kubectlVersion, skew)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return errors.Errorf("kubectlVersion %s is not within the supported skew of helm %s, which supports Kubernetes 1.%d: use kubectl 1.%d through 1.%d",
+			kubectlVersion, helmVersion, kubeMinor, kubeMinor-1, kubeMinor+1)
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+package helm2
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateKubectlVersion(t *testing.T) {
+	testcases := map[string]struct {
+		kubectl string
+		helm    string
+		wantErr string
+	}{
+		"default":          {kubectl: kubeVersion, helm: "v2.17.0"},
+		"newest skew":      {kubectl: "v1.17.4", helm: "v2.17.0"},
+		"oldest skew":      {kubectl: "v1.13.0", helm: "v2.14.3"},
+		"unknown helm":     {kubectl: "v1.22.0", helm: "v2.18.0"},
+		"not semver":       {kubectl: "latest", helm: "v2.17.0", wantErr: `supplied kubectlVersion "latest" cannot be parsed as semver: Invalid Semantic Version`},
+		"partial version":  {kubectl: "v1.16", helm: "v2.17.0", wantErr: `supplied kubectlVersion "v1.16" must be a full version, such as v1.15.3`},
+		"not kubectl 1.x":  {kubectl: "v2.0.0", helm: "v2.17.0", wantErr: `supplied kubectlVersion "v2.0.0" does not meet semver constraint "^v1.x"`},
+		"too new for helm": {kubectl: "v1.20.0", helm: "v2.17.0", wantErr: "kubectlVersion v1.20.0 is not within the supported skew of helm v2.17.0, which supports Kubernetes 1.16: use kubectl 1.15 through 1.17"},
+		"too old for helm": {kubectl: "v1.12.10", helm: "v2.16.1", wantErr: "kubectlVersion v1.12.10 is not within the supported skew of helm v2.16.1, which supports Kubernetes 1.16: use kubectl 1.15 through 1.17"},
+	}
+	for name, tc := range testcases {
+		t.Run(name, func(t *testing.T) {
+			err := validateKubectlVersion(tc.kubectl, tc.helm)
+			if tc.wantErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestMixinConfig_GetKubectlVersion(t *testing.T) {
+	assert.Equal(t, kubeVersion, MixinConfig{}.GetKubectlVersion())
+	assert.Equal(t, "v1.16.3", MixinConfig{KubectlVersion: "1.16.3"}.GetKubectlVersion())
+	assert.Equal(t, "v1.16.3", MixinConfig{KubectlVersion: "v1.16.3"}.GetKubectlVersion())
+}
+
+func TestMixin_GetOutput_WithoutKubectl(t *testing.T) {
+	m := NewTestMixin(t)
+	disabled := false
+	m.Config.Kubectl = &disabled
+
+	_, err := m.getOutput("service", "mysql", "default", "{.spec.clusterIP}")
+	require.EqualError(t, err, "cannot get jsonPath output from service mysql because kubectl is not installed, "+
+		"remove kubectl: false from the mixin configuration")
+}
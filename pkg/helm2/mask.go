@@ -0,0 +1,210 @@
+package helm2
+
+import (
+	"fmt"
+	"io"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"get.porter.sh/porter/pkg/exec/builder"
+)
+
+// maskedValue replaces sensitive values in the commands that the mixin prints
+const maskedValue string = "*******"
+
+// minMaskedOutputLength is the length of the shortest value that is masked in helm's
+// output, shorter values are only masked in the printed command
+const minMaskedOutputLength int = 4
+
+// sensitiveKeyPatterns mark a key as sensitive when any of the words in it is one of them, ignoring case
+var sensitiveKeyPatterns = []string{"password", "token", "secret", "key"}
+
+// setFlagNames are the flags whose key=value pairs are checked for sensitive keys
+var setFlagNames = []string{"--set", "--set-string"}
+
+// IsSensitiveKey returns whether the value of a --set key should be masked: when
+// it matches one of the default patterns, or is marked sensitive in the mixin configuration.
+func (c RuntimeConfig) IsSensitiveKey(key string) bool {
+	// Compare the keys without their escapes, which may be added for helm
+	key = unescapeSetKey(key)
+	for _, k := range c.SensitiveKeys {
+		if unescapeSetKey(k) == key {
+			return true
+		}
+	}
+
+	for _, word := range keyWords(key) {
+		for _, p := range sensitiveKeyPatterns {
+			if strings.EqualFold(word, p) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// keyWords splits a --set key into its words, at the separators between the
+// segments of the key and at camel case boundaries, so that mysqlRootPassword
+// is mysql, Root and Password, and keycloak.enabled is keycloak and enabled.
+func keyWords(key string) []string {
+	var words []string
+	runes := []rune(key)
+	start := 0
+	for i, r := range runes {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			if start < i {
+				words = append(words, string(runes[start:i]))
+			}
+			start = i + 1
+			continue
+		}
+		if i == start || !unicode.IsUpper(r) {
+			continue
+		}
+		// Start a new word at an upper case letter that follows a lower case letter or a
+		// digit, or that ends an acronym and starts a word, such as the K in APIKey
+		prev := runes[i-1]
+		if unicode.IsLower(prev) || unicode.IsDigit(prev) ||
+			(i+1 < len(runes) && unicode.IsLower(runes[i+1])) {
+			words = append(words, string(runes[start:i]))
+			start = i
+		}
+	}
+	if start < len(runes) {
+		words = append(words, string(runes[start:]))
+	}
+	return words
+}
+
+// maskArgs returns a copy of the args with the sensitive values replaced by maskedValue,
+// and the values that were masked.
+func (c RuntimeConfig) maskArgs(args []string) ([]string, []string) {
+	masked := make([]string, len(args))
+	var secrets []string
+	for i, arg := range args {
+		masked[i] = arg
+
+		if i > 0 && args[i-1] == "--password" {
+			masked[i] = maskedValue
+			secrets = append(secrets, arg)
+			continue
+		}
+		if strings.HasPrefix(arg, "--password=") {
+			masked[i] = "--password=" + maskedValue
+			secrets = append(secrets, strings.TrimPrefix(arg, "--password="))
+			continue
+		}
+
+		for _, flag := range setFlagNames {
+			var pair string
+			switch {
+			case i > 0 && args[i-1] == flag:
+				pair = arg
+			case strings.HasPrefix(arg, flag+"="):
+				pair = strings.TrimPrefix(arg, flag+"=")
+			default:
+				continue
+			}
+
+			key, value, ok := splitSetPair(pair)
+			if ok && value != "" && c.IsSensitiveKey(key) {
+				masked[i] = strings.TrimSuffix(arg, value) + maskedValue
+				secrets = append(secrets, value)
+			}
+			break
+		}
+	}
+	return masked, secrets
+}
+
+// unescapeSetKey removes the escapes from a --set key.
+func unescapeSetKey(key string) string {
+	return strings.Replace(key, `\`, "", -1)
+}
+
+// splitSetPair splits a key=value pair from a --set flag at the first equals sign
+// that isn't escaped in the key.
+func splitSetPair(pair string) (string, string, bool) {
+	escaped := false
+	for i, r := range pair {
+		switch {
+		case escaped:
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case r == '=':
+			return pair[:i], pair[i+1:], true
+		}
+	}
+	return pair, "", false
+}
+
+// prettyCommand returns the command to print, with the sensitive values masked.
+func (m *Mixin) prettyCommand(cmd *exec.Cmd) string {
+	args, _ := m.Config.maskArgs(cmd.Args)
+	return fmt.Sprintf("%s %s", cmd.Path, strings.Join(args, " "))
+}
+
+// sensitiveFlagValues returns the sensitive values in the arguments and flags of an
+// invoke step, which porter includes in the command that it prints.
+func (c RuntimeConfig) sensitiveFlagValues(arguments []string, flags builder.Flags) []string {
+	args := append([]string{}, arguments...)
+	for _, f := range flags {
+		for _, v := range f.Values {
+			args = append(args, "--"+f.Name, v)
+		}
+	}
+	_, secrets := c.maskArgs(args)
+	return secrets
+}
+
+// maskValues replaces each of the secrets in s with maskedValue.
+func maskValues(s string, secrets []string) string {
+	// Replace the longest secrets first, so that a secret that contains another is fully masked
+	sorted := append([]string{}, secrets...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return len(sorted[i]) > len(sorted[j])
+	})
+	for _, secret := range sorted {
+		if secret != "" {
+			s = strings.Replace(s, secret, maskedValue, -1)
+		}
+	}
+	return s
+}
+
+// maskingWriter masks secrets in the output written to it.
+type maskingWriter struct {
+	w       io.Writer
+	secrets []string
+}
+
+// addSecrets masks the values in the output, except those that are too short or
+// common to replace in all of the output without corrupting it, such as true.
+func (w *maskingWriter) addSecrets(values ...string) {
+	for _, v := range values {
+		if len(v) < minMaskedOutputLength {
+			continue
+		}
+		if _, err := strconv.ParseBool(v); err == nil {
+			continue
+		}
+		w.secrets = append(w.secrets, v)
+	}
+}
+
+func (w *maskingWriter) Write(p []byte) (int, error) {
+	_, err := io.WriteString(w.w, w.mask(string(p)))
+	if err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// mask replaces the secrets in s with maskedValue.
+func (w *maskingWriter) mask(s string) string {
+	return maskValues(s, w.secrets)
+}
@@ -0,0 +1,149 @@
+package helm2
+
+import (
+	"bytes"
+	"testing"
+
+	"get.porter.sh/porter/pkg/exec/builder"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	yaml "gopkg.in/yaml.v2"
+)
+
+func TestRuntimeConfig_IsSensitiveKey(t *testing.T) {
+	cfg := RuntimeConfig{SensitiveKeys: []string{"db.connectionString", "annotations.example\\.com/license"}}
+
+	for _, key := range []string{"mysqlRootPassword", "auth.token", "existingSecret", "apiKey", "APIKey", "tls.key", "TOKEN",
+		"auth_token", "users[0].password", "db.connectionString", `annotations.example\.com/license`} {
+		assert.True(t, cfg.IsSensitiveKey(key), key)
+	}
+	for _, key := range []string{"mysqlUser", "image.tag", "db.connection", "replicas", "keycloak.enabled", "monkey", "service.keys", "tokenizer"} {
+		assert.False(t, cfg.IsSensitiveKey(key), key)
+	}
+}
+
+func TestKeyWords(t *testing.T) {
+	assert.Equal(t, []string{"mysql", "Root", "Password"}, keyWords("mysqlRootPassword"))
+	assert.Equal(t, []string{"API", "Key"}, keyWords("APIKey"))
+	assert.Equal(t, []string{"users", "0", "password"}, keyWords("users[0].password"))
+	assert.Equal(t, []string{"keycloak", "enabled"}, keyWords("keycloak.enabled"))
+	assert.Equal(t, []string{"tls2", "Key"}, keyWords("tls2Key"))
+}
+
+func TestRuntimeConfig_MaskArgs(t *testing.T) {
+	cfg := RuntimeConfig{SensitiveKeys: []string{"db.url"}}
+	args := []string{"helm", "install", "--name", "mysql", "stable/mysql",
+		"--set", "mysqlUser=admin",
+		"--set", `mysqlPassword=p@ss\,word`,
+		"--set-string", `db.url=jdbc:mysql://db?user\=admin`,
+		"--set-file", "tls.key=/cnab/app/tls.key",
+		"--set-string=auth.token=abc123",
+		"--password", "hunter2",
+	}
+
+	masked, secrets := cfg.maskArgs(args)
+	assert.Equal(t, []string{"helm", "install", "--name", "mysql", "stable/mysql",
+		"--set", "mysqlUser=admin",
+		"--set", "mysqlPassword=*******",
+		"--set-string", "db.url=*******",
+		"--set-file", "tls.key=/cnab/app/tls.key",
+		"--set-string=auth.token=*******",
+		"--password", "*******",
+	}, masked, "only the values of sensitive keys should be masked, and files are not read")
+	assert.Equal(t, []string{`p@ss\,word`, `jdbc:mysql://db?user\=admin`, "abc123", "hunter2"}, secrets)
+	assert.Equal(t, "mysqlPassword=p@ss\\,word", args[8], "the args should not be changed")
+}
+
+func TestMaskValues(t *testing.T) {
+	assert.Equal(t, "helm upgrade --set password=******* --set token=*******",
+		maskValues("helm upgrade --set password=s3cret --set token=s3cret-token", []string{"s3cret", "s3cret-token"}))
+	assert.Equal(t, "nothing to mask", maskValues("nothing to mask", nil))
+}
+
+func TestMaskingWriter_AddSecrets(t *testing.T) {
+	var out bytes.Buffer
+	masker := &maskingWriter{w: &out}
+	masker.addSecrets("s3cret", "true", "1", "abc", "")
+	assert.Equal(t, []string{"s3cret"}, masker.secrets, "short and boolean values should not be masked in all of the output")
+
+	_, err := masker.Write([]byte("password=s3cret enabled=true replicas=1"))
+	require.NoError(t, err)
+	assert.Equal(t, "password=******* enabled=true replicas=1", out.String())
+}
+
+func TestMixin_LoadAction_MasksOnlySensitiveValues(t *testing.T) {
+	h := NewTestMixin(t)
+
+	action := Action{Steps: []ExecuteStep{{ExecuteInstruction: ExecuteInstruction{
+		Step:      Step{Description: "Upgrade Keycloak"},
+		Arguments: []string{"upgrade", "keycloak", "codecentric/keycloak"},
+		Flags: builder.Flags{{Name: "set", Values: []string{
+			"keycloak.enabled=true",
+			"monkey=admin",
+			"keycloak.password=s3cret",
+			"auth.token=true",
+		}}},
+	}}}}
+	b, err := yaml.Marshal(action)
+	require.NoError(t, err)
+	h.In = bytes.NewReader(b)
+
+	var stderr bytes.Buffer
+	masker := &maskingWriter{w: &stderr}
+	_, err = h.loadAction(masker)
+	require.NoError(t, err)
+
+	_, err = masker.Write([]byte("helm upgrade keycloak --set keycloak.enabled=true --set monkey=admin --set keycloak.password=s3cret\n" +
+		"Error: admin user s3cret is invalid, tls: true, rbac.create: true\n"))
+	require.NoError(t, err)
+	assert.Equal(t, "helm upgrade keycloak --set keycloak.enabled=true --set monkey=admin --set keycloak.password=*******\n"+
+		"Error: admin user ******* is invalid, tls: true, rbac.create: true\n", stderr.String(),
+		"only the values of sensitive keys should be masked in helm's output")
+}
+
+func TestMixin_Install_MasksSensitiveValues(t *testing.T) {
+	h := NewTestMixin(t)
+	err := h.FileSystem.WriteFile(runtimeConfigPath, []byte("sensitiveKeys:\n- licenseCode\n"), 0644)
+	require.NoError(t, err)
+
+	action := InstallAction{Steps: []InstallStep{{InstallArguments: InstallArguments{
+		Step:  Step{Description: "Install MySQL"},
+		Name:  "mysql",
+		Chart: "stable/mysql",
+		Set:   map[string]string{"mysqlRootPassword": "s3cret", "mysqlUser": "admin"},
+		SetString: map[string]string{
+			"licenseCode": "0123-4567",
+		},
+	}}}}
+	b, err := yaml.Marshal(action)
+	require.NoError(t, err)
+	h.In = bytes.NewReader(b)
+
+	err = h.Install()
+	require.NoError(t, err)
+
+	gotOutput := h.TestContext.GetOutput()
+	assert.Contains(t, gotOutput, "helm install --name mysql stable/mysql --set mysqlRootPassword=******* --set mysqlUser=admin --set-string licenseCode=*******")
+	assert.NotContains(t, gotOutput, "s3cret")
+	assert.NotContains(t, gotOutput, "0123-4567")
+}
+
+func TestMixin_Execute_MasksSensitiveValues(t *testing.T) {
+	h := NewTestMixin(t)
+	h.Debug = true
+
+	action := Action{Steps: []ExecuteStep{{ExecuteInstruction: ExecuteInstruction{
+		Step:      Step{Description: "Upgrade MySQL"},
+		Arguments: []string{"upgrade", "mysql", "stable/mysql"},
+		Flags:     builder.Flags{{Name: "set", Values: []string{"mysqlRootPassword=s3cret"}}},
+	}}}}
+	b, err := yaml.Marshal(action)
+	require.NoError(t, err)
+	h.In = bytes.NewReader(b)
+
+	err = h.Execute()
+	require.NoError(t, err)
+
+	assert.NotContains(t, h.TestContext.GetError(), "s3cret", "the command printed in debug mode should be masked")
+	assert.NotContains(t, h.TestContext.GetOutput(), "s3cret")
+}
@@ -18,6 +18,9 @@ func getSecret(client kubernetes.Interface, namespace, name, key string) ([]byte
 	if apierrors.IsNotFound(err) {
 		return nil, fmt.Errorf("error getting secret %s from namespace %s: %s", name, namespace, err)
 	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "error getting secret %s from namespace %s", name, namespace)
+	}
 	val, ok := secret.Data[key]
 	if !ok {
 		return nil, fmt.Errorf("couldn't find key %s in secret", key)
@@ -26,12 +29,17 @@ func getSecret(client kubernetes.Interface, namespace, name, key string) ([]byte
 }
 
 func (m *Mixin) getOutput(resourceType, resourceName, namespace, jsonPath string) ([]byte, error) {
+	if !m.Config.InstallKubectl() {
+		return nil, errors.Errorf("cannot get jsonPath output from %s %s because kubectl is not installed, remove kubectl: false from the mixin configuration",
+			resourceType, resourceName)
+	}
+
 	args := []string{"get", resourceType, resourceName}
 	args = append(args, fmt.Sprintf("-o=jsonpath=%s", jsonPath))
 	if namespace != "" {
 		args = append(args, fmt.Sprintf("--namespace=%s", namespace))
 	}
-	cmd := m.NewCommand("kubectl", args...)
+	cmd := m.newKubectlCommand(args...)
 	cmd.Stderr = m.Err
 	out, err := cmd.Output()
 	if err != nil {
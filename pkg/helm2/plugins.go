@@ -0,0 +1,175 @@
+package helm2
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// helmPluginsDir is where helm installs plugins, in $HELM_HOME
+const helmPluginsDir string = "/root/.helm/plugins"
+
+// bundledPluginsDir is where plugins from the bundle directory are copied before they are installed
+const bundledPluginsDir string = "/var/lib/helm2-mixin/plugins"
+
+var pluginName = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// Plugin is a helm plugin that is installed in the invocation image.
+type Plugin struct {
+	// Name of the plugin, which is also the helm command that runs it
+	Name string `yaml:"name"`
+
+	// Source is the URL of the plugin, or its path in the bundle directory
+	Source string `yaml:"source"`
+
+	// Version of the plugin, required when the source is a URL
+	Version string `yaml:"version,omitempty"`
+}
+
+// IsRemote returns whether the plugin is downloaded from its source when the image is built.
+func (p Plugin) IsRemote() bool {
+	return strings.Contains(p.Source, "://")
+}
+
+// Validate checks that the plugin has a name and source, and that a remote plugin is pinned to a version.
+func (p Plugin) Validate() error {
+	if !pluginName.MatchString(p.Name) {
+		return errors.Errorf("invalid plugin name %q, it may only contain letters, numbers, '-' and '_'", p.Name)
+	}
+	if p.Source == "" {
+		return errors.Errorf("plugin %s: source must be supplied", p.Name)
+	}
+	if p.IsRemote() {
+		if p.Version == "" {
+			return errors.Errorf("plugin %s: version must be supplied to pin the plugin downloaded from %s", p.Name, p.Source)
+		}
+		return nil
+	}
+
+	cleaned := filepath.ToSlash(filepath.Clean(p.Source))
+	if filepath.IsAbs(p.Source) || cleaned == ".." || strings.HasPrefix(cleaned, "../") {
+		return errors.Errorf("plugin %s: invalid source %q, it must be a URL or a relative path inside the bundle directory", p.Name, p.Source)
+	}
+	return nil
+}
+
+// validatePlugins checks each of the plugins, and that their names are unique.
+func validatePlugins(plugins []Plugin) error {
+	names := make(map[string]bool, len(plugins))
+	for _, p := range plugins {
+		err := p.Validate()
+		if err != nil {
+			return err
+		}
+		if names[p.Name] {
+			return errors.Errorf("plugin %s is defined more than once", p.Name)
+		}
+		names[p.Name] = true
+	}
+	return nil
+}
+
+// hasRemotePlugins returns whether any of the plugins is downloaded when the image is built.
+func hasRemotePlugins(plugins []Plugin) bool {
+	for _, p := range plugins {
+		if p.IsRemote() {
+			return true
+		}
+	}
+	return false
+}
+
+// pluginInstallCommands returns the commands that install the plugins, after the
+// bundled plugins have been copied into the image.
+func pluginInstallCommands(plugins []Plugin) []string {
+	commands := make([]string, 0, len(plugins))
+	for _, p := range plugins {
+		if p.IsRemote() {
+			commands = append(commands, fmt.Sprintf("helm plugin install %s --version %s", p.Source, p.Version))
+		} else {
+			commands = append(commands, "helm plugin install "+path.Join(bundledPluginsDir, p.Name))
+		}
+	}
+	return commands
+}
+
+// pluginMetadata is the part of a plugin's plugin.yaml that identifies it
+type pluginMetadata struct {
+	Name    string `yaml:"name"`
+	Version string `yaml:"version"`
+}
+
+// getInstalledPlugins returns the versions of the installed plugins, by name.
+func (m *Mixin) getInstalledPlugins() (map[string]string, error) {
+	installed := make(map[string]string)
+	exists, err := m.FileSystem.DirExists(helmPluginsDir)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not check for helm plugins in %s", helmPluginsDir)
+	}
+	if !exists {
+		return installed, nil
+	}
+
+	dirs, err := m.FileSystem.ReadDir(helmPluginsDir)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not list the helm plugins in %s", helmPluginsDir)
+	}
+
+	for _, dir := range dirs {
+		manifest := path.Join(helmPluginsDir, dir.Name(), "plugin.yaml")
+		exists, err := m.FileSystem.Exists(manifest)
+		if err != nil {
+			return nil, errors.Wrapf(err, "could not check for helm plugin %s", manifest)
+		}
+		if !exists {
+			continue
+		}
+
+		b, err := m.FileSystem.ReadFile(manifest)
+		if err != nil {
+			return nil, errors.Wrapf(err, "could not read helm plugin %s", manifest)
+		}
+		var metadata pluginMetadata
+		err = yaml.Unmarshal(b, &metadata)
+		if err != nil {
+			return nil, errors.Wrapf(err, "could not parse helm plugin %s", manifest)
+		}
+		installed[metadata.Name] = metadata.Version
+	}
+	return installed, nil
+}
+
+// checkPlugin verifies that the plugin run by a step's arguments is installed,
+// at the version that the mixin configuration pins.
+func (m *Mixin) checkPlugin(args []string) error {
+	if len(args) == 0 {
+		return nil
+	}
+
+	for _, p := range m.Config.Plugins {
+		if p.Name != args[0] {
+			continue
+		}
+
+		installed, err := m.getInstalledPlugins()
+		if err != nil {
+			return err
+		}
+		version, ok := installed[p.Name]
+		if !ok {
+			return errors.Errorf("helm plugin %s is required by this step but is not installed in %s, rebuild the bundle to install it",
+				p.Name, helmPluginsDir)
+		}
+		if p.Version != "" && strings.TrimPrefix(version, "v") != strings.TrimPrefix(p.Version, "v") {
+			return errors.Errorf("helm plugin %s is installed at version %s, but the mixin configuration requires version %s",
+				p.Name, version, p.Version)
+		}
+		return nil
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+package helm2
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPlugin_Validate(t *testing.T) {
+	testcases := map[string]struct {
+		plugin  Plugin
+		wantErr string
+	}{
+		"remote":          {plugin: Plugin{Name: "diff", Source: "https://github.com/databus23/helm-diff", Version: "v3.1.3"}},
+		"local":           {plugin: Plugin{Name: "lint", Source: "plugins/lint"}},
+		"invalid name":    {plugin: Plugin{Name: "diff; rm -rf /", Source: "plugins/diff"}, wantErr: `invalid plugin name "diff; rm -rf /", it may only contain letters, numbers, '-' and '_'`},
+		"missing source":  {plugin: Plugin{Name: "diff"}, wantErr: "plugin diff: source must be supplied"},
+		"unpinned remote": {plugin: Plugin{Name: "diff", Source: "https://github.com/databus23/helm-diff"}, wantErr: "plugin diff: version must be supplied to pin the plugin downloaded from https://github.com/databus23/helm-diff"},
+		"absolute path":   {plugin: Plugin{Name: "lint", Source: "/opt/lint"}, wantErr: `plugin lint: invalid source "/opt/lint", it must be a URL or a relative path inside the bundle directory`},
+		"outside bundle":  {plugin: Plugin{Name: "lint", Source: "../lint"}, wantErr: `plugin lint: invalid source "../lint", it must be a URL or a relative path inside the bundle directory`},
+	}
+	for name, tc := range testcases {
+		t.Run(name, func(t *testing.T) {
+			err := tc.plugin.Validate()
+			if tc.wantErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidatePlugins_Duplicate(t *testing.T) {
+	plugins := []Plugin{
+		{Name: "lint", Source: "plugins/lint"},
+		{Name: "lint", Source: "plugins/lint-v2"},
+	}
+	assert.EqualError(t, validatePlugins(plugins), "plugin lint is defined more than once")
+}
+
+func TestPluginInstallCommands(t *testing.T) {
+	plugins := []Plugin{
+		{Name: "diff", Source: "https://github.com/databus23/helm-diff", Version: "v3.1.3"},
+		{Name: "lint", Source: "plugins/lint"},
+	}
+	assert.True(t, hasRemotePlugins(plugins))
+	assert.Equal(t, []string{
+		"helm plugin install https://github.com/databus23/helm-diff --version v3.1.3",
+		"helm plugin install /var/lib/helm2-mixin/plugins/lint",
+	}, pluginInstallCommands(plugins))
+}
+
+func TestMixin_CheckPlugin(t *testing.T) {
+	h := NewTestMixin(t)
+	h.Config.Plugins = []Plugin{
+		{Name: "diff", Source: "https://github.com/databus23/helm-diff", Version: "v3.1.3"},
+		{Name: "lint", Source: "plugins/lint"},
+	}
+
+	err := h.checkPlugin([]string{"diff", "upgrade", "mysql", "stable/mysql"})
+	assert.EqualError(t, err, "helm plugin diff is required by this step but is not installed in /root/.helm/plugins, rebuild the bundle to install it")
+
+	err = h.FileSystem.WriteFile("/root/.helm/plugins/helm-diff/plugin.yaml", []byte("name: diff\nversion: 3.1.3\n"), 0644)
+	require.NoError(t, err)
+	err = h.FileSystem.WriteFile("/root/.helm/plugins/lint/plugin.yaml", []byte("name: lint\nversion: 0.1.0\n"), 0644)
+	require.NoError(t, err)
+
+	assert.NoError(t, h.checkPlugin([]string{"diff", "upgrade", "mysql", "stable/mysql"}), "the v prefix should be ignored")
+	assert.NoError(t, h.checkPlugin([]string{"lint"}), "local plugins are not pinned")
+	assert.NoError(t, h.checkPlugin([]string{"status", "mysql"}), "commands that aren't plugins should not be checked")
+
+	h.Config.Plugins[0].Version = "v3.1.2"
+	err = h.checkPlugin([]string{"diff", "upgrade", "mysql", "stable/mysql"})
+	assert.EqualError(t, err, "helm plugin diff is installed at version 3.1.3, but the mixin configuration requires version v3.1.2")
+}
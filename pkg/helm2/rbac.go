@@ -0,0 +1,345 @@
+package helm2
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// The RBAC modes that control what Tiller's service account may do
+const (
+	// rbacModeClusterAdmin binds Tiller to the cluster-admin ClusterRole
+	rbacModeClusterAdmin string = "clusterAdmin"
+
+	// rbacModeClusterRole binds Tiller to an existing ClusterRole
+	rbacModeClusterRole string = "clusterRole"
+
+	// rbacModeNamespaced limits Tiller to a Role in each of the target namespaces
+	rbacModeNamespaced string = "namespaced"
+
+	// rbacModeNone leaves RBAC setup to the cluster administrator
+	rbacModeNone string = "none"
+)
+
+const clusterAdminRole string = "cluster-admin"
+
+// RBACConfig controls the permissions that the mixin grants to Tiller's service account
+// when it initializes Tiller.
+type RBACConfig struct {
+	// Mode is one of clusterAdmin (default), clusterRole, namespaced or none
+	Mode string `yaml:"mode,omitempty"`
+
+	// ClusterRole is the name of an existing ClusterRole that Tiller is bound to in clusterRole mode
+	ClusterRole string `yaml:"clusterRole,omitempty"`
+
+	// Namespaces that Tiller may deploy to in namespaced mode. Tiller's own namespace is always included.
+	Namespaces []string `yaml:"namespaces,omitempty"`
+}
+
+// GetMode returns the RBAC mode, defaulting to clusterAdmin.
+func (c RBACConfig) GetMode() string {
+	if c.Mode == "" {
+		return rbacModeClusterAdmin
+	}
+	return c.Mode
+}
+
+// GetClusterRole returns the ClusterRole bound to Tiller in the cluster-wide modes.
+func (c RBACConfig) GetClusterRole() string {
+	if c.GetMode() == rbacModeClusterRole {
+		return c.ClusterRole
+	}
+	return clusterAdminRole
+}
+
+// GetNamespaces returns the namespaces where Tiller is granted a Role in namespaced mode.
+func (c RBACConfig) GetNamespaces(tiller TillerSettings) []string {
+	namespaces := []string{tiller.GetTillerNamespace()}
+	for _, ns := range c.Namespaces {
+		if ns != tiller.GetTillerNamespace() {
+			namespaces = append(namespaces, ns)
+		}
+	}
+	return namespaces
+}
+
+// Validate checks that the RBAC mode is known and has the settings that it requires.
+func (c RBACConfig) Validate() error {
+	switch c.GetMode() {
+	case rbacModeClusterAdmin, rbacModeNone, rbacModeNamespaced:
+		return nil
+	case rbacModeClusterRole:
+		if c.ClusterRole == "" {
+			return errors.Errorf("rbac mode %q requires clusterRole to be set", rbacModeClusterRole)
+		}
+		return nil
+	default:
+		return errors.Errorf("invalid rbac mode %q, allowed values are: %s", c.Mode,
+			strings.Join([]string{rbacModeClusterAdmin, rbacModeClusterRole, rbacModeNamespaced, rbacModeNone}, ", "))
+	}
+}
+
+// Describe explains the permissions that will be granted to Tiller, for the Init log.
+func (c RBACConfig) Describe(tiller TillerSettings) string {
+	serviceAccount := fmt.Sprintf("%s:%s", tiller.GetTillerNamespace(), tiller.GetServiceAccount())
+
+	switch c.GetMode() {
+	case rbacModeNone:
+		return fmt.Sprintf("RBAC mode %s: skipping RBAC setup for service account %s", rbacModeNone, serviceAccount)
+	case rbacModeNamespaced:
+		return fmt.Sprintf("RBAC mode %s: granting service account %s a Role in namespaces %s",
+			rbacModeNamespaced, serviceAccount, strings.Join(c.GetNamespaces(tiller), ", "))
+	default:
+		return fmt.Sprintf("RBAC mode %s: binding service account %s to ClusterRole %s",
+			c.GetMode(), serviceAccount, c.GetClusterRole())
+	}
+}
+
+// RBACError is returned when one of Tiller's RBAC resources could not be reconciled.
+type RBACError struct {
+	// Kind of the resource, such as ServiceAccount or ClusterRoleBinding
+	Kind string
+
+	// Namespace of the resource, empty for cluster scoped resources
+	Namespace string
+
+	// Name of the resource
+	Name string
+
+	// Err is the error returned by the Kubernetes API
+	Err error
+}
+
+func (e RBACError) Error() string {
+	if e.Namespace == "" {
+		return fmt.Sprintf("unable to reconcile %s %s: %s", e.Kind, e.Name, e.Err)
+	}
+	return fmt.Sprintf("unable to reconcile %s %s/%s: %s", e.Kind, e.Namespace, e.Name, e.Err)
+}
+
+// Cause returns the underlying Kubernetes API error.
+func (e RBACError) Cause() error {
+	return e.Err
+}
+
+// Unwrap returns the underlying Kubernetes API error.
+func (e RBACError) Unwrap() error {
+	return e.Err
+}
+
+// tillerBindingName returns the name of the binding that grants Tiller its role.
+// Tillers outside of kube-system are qualified by their namespace, so that
+// tenants using the same service account name don't share a binding.
+func tillerBindingName(s TillerSettings) string {
+	if s.GetTillerNamespace() == defaultTillerNamespace {
+		return s.GetServiceAccount()
+	}
+	return fmt.Sprintf("%s-%s", s.GetTillerNamespace(), s.GetServiceAccount())
+}
+
+// reconcileTillerRBAC creates Tiller's service account and grants it the role selected
+// by the RBAC mode, updating any existing resources that have drifted.
+func (m *Mixin) reconcileTillerRBAC(client kubernetes.Interface) error {
+	err := m.reconcileServiceAccount(client)
+	if err != nil {
+		return err
+	}
+
+	name := tillerBindingName(m.Tiller)
+	subjects := []rbacv1.Subject{{
+		Kind:      rbacv1.ServiceAccountKind,
+		Name:      m.Tiller.GetServiceAccount(),
+		Namespace: m.Tiller.GetTillerNamespace(),
+	}}
+	rbac := m.Config.RBAC
+
+	if rbac.GetMode() != rbacModeNamespaced {
+		binding := &rbacv1.ClusterRoleBinding{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			RoleRef: rbacv1.RoleRef{
+				APIGroup: rbacv1.GroupName,
+				Kind:     "ClusterRole",
+				Name:     rbac.GetClusterRole(),
+			},
+			Subjects: subjects,
+		}
+		return m.reconcileClusterRoleBinding(client, binding)
+	}
+
+	// Limit Tiller to a Role in each namespace that it manages, including its own
+	// where it stores release information
+	for _, ns := range rbac.GetNamespaces(m.Tiller) {
+		role := &rbacv1.Role{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: ns},
+			Rules: []rbacv1.PolicyRule{{
+				APIGroups: []string{rbacv1.APIGroupAll},
+				Resources: []string{rbacv1.ResourceAll},
+				Verbs:     []string{rbacv1.VerbAll},
+			}},
+		}
+		err = m.reconcileRole(client, role)
+		if err != nil {
+			return err
+		}
+
+		binding := &rbacv1.RoleBinding{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: ns},
+			RoleRef: rbacv1.RoleRef{
+				APIGroup: rbacv1.GroupName,
+				Kind:     "Role",
+				Name:     name,
+			},
+			Subjects: subjects,
+		}
+		err = m.reconcileRoleBinding(client, binding)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *Mixin) reconcileServiceAccount(client kubernetes.Interface) error {
+	namespace := m.Tiller.GetTillerNamespace()
+	name := m.Tiller.GetServiceAccount()
+	rbacErr := func(err error) error {
+		return RBACError{Kind: "ServiceAccount", Namespace: namespace, Name: name, Err: err}
+	}
+
+	serviceAccounts := client.CoreV1().ServiceAccounts(namespace)
+	_, err := serviceAccounts.Get(name, metav1.GetOptions{})
+	if err == nil {
+		return nil
+	}
+	if !apierrors.IsNotFound(err) {
+		return rbacErr(err)
+	}
+
+	sa := &corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace}}
+	_, err = serviceAccounts.Create(sa)
+	if err != nil && !apierrors.IsAlreadyExists(err) {
+		return rbacErr(err)
+	}
+	return nil
+}
+
+func (m *Mixin) reconcileClusterRoleBinding(client kubernetes.Interface, want *rbacv1.ClusterRoleBinding) error {
+	rbacErr := func(err error) error {
+		return RBACError{Kind: "ClusterRoleBinding", Name: want.Name, Err: err}
+	}
+
+	bindings := client.RbacV1().ClusterRoleBindings()
+	got, err := bindings.Get(want.Name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		_, err = bindings.Create(want)
+		if err != nil {
+			return rbacErr(err)
+		}
+		return nil
+	}
+	if err != nil {
+		return rbacErr(err)
+	}
+
+	// The role of a binding cannot be changed, it must be replaced
+	if got.RoleRef != want.RoleRef {
+		fmt.Fprintf(m.Out, "ClusterRoleBinding %s is bound to %s %s, replacing it to bind to %s %s\n",
+			want.Name, got.RoleRef.Kind, got.RoleRef.Name, want.RoleRef.Kind, want.RoleRef.Name)
+		err = bindings.Delete(want.Name, &metav1.DeleteOptions{})
+		if err != nil {
+			return rbacErr(err)
+		}
+		_, err = bindings.Create(want)
+		if err != nil {
+			return rbacErr(err)
+		}
+		return nil
+	}
+
+	if !reflect.DeepEqual(got.Subjects, want.Subjects) {
+		got.Subjects = want.Subjects
+		_, err = bindings.Update(got)
+		if err != nil {
+			return rbacErr(err)
+		}
+	}
+	return nil
+}
+
+func (m *Mixin) reconcileRole(client kubernetes.Interface, want *rbacv1.Role) error {
+	rbacErr := func(err error) error {
+		return RBACError{Kind: "Role", Namespace: want.Namespace, Name: want.Name, Err: err}
+	}
+
+	roles := client.RbacV1().Roles(want.Namespace)
+	got, err := roles.Get(want.Name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		_, err = roles.Create(want)
+		if err != nil {
+			return rbacErr(err)
+		}
+		return nil
+	}
+	if err != nil {
+		return rbacErr(err)
+	}
+
+	if !reflect.DeepEqual(got.Rules, want.Rules) {
+		got.Rules = want.Rules
+		_, err = roles.Update(got)
+		if err != nil {
+			return rbacErr(err)
+		}
+	}
+	return nil
+}
+
+func (m *Mixin) reconcileRoleBinding(client kubernetes.Interface, want *rbacv1.RoleBinding) error {
+	rbacErr := func(err error) error {
+		return RBACError{Kind: "RoleBinding", Namespace: want.Namespace, Name: want.Name, Err: err}
+	}
+
+	bindings := client.RbacV1().RoleBindings(want.Namespace)
+	got, err := bindings.Get(want.Name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		_, err = bindings.Create(want)
+		if err != nil {
+			return rbacErr(err)
+		}
+		return nil
+	}
+	if err != nil {
+		return rbacErr(err)
+	}
+
+	// The role of a binding cannot be changed, it must be replaced
+	if got.RoleRef != want.RoleRef {
+		fmt.Fprintf(m.Out, "RoleBinding %s/%s is bound to %s %s, replacing it to bind to %s %s\n",
+			want.Namespace, want.Name, got.RoleRef.Kind, got.RoleRef.Name, want.RoleRef.Kind, want.RoleRef.Name)
+		err = bindings.Delete(want.Name, &metav1.DeleteOptions{})
+		if err != nil {
+			return rbacErr(err)
+		}
+		_, err = bindings.Create(want)
+		if err != nil {
+			return rbacErr(err)
+		}
+		return nil
+	}
+
+	if !reflect.DeepEqual(got.Subjects, want.Subjects) {
+		got.Subjects = want.Subjects
+		_, err = bindings.Update(got)
+		if err != nil {
+			return rbacErr(err)
+		}
+	}
+	return nil
+}
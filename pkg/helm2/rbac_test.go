@@ -0,0 +1,133 @@
+package helm2
+
+import (
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestRBACConfig_Validate(t *testing.T) {
+	testcases := []struct {
+		name  string
+		rbac  RBACConfig
+		error string
+	}{
+		{"default", RBACConfig{}, ""},
+		{"namespaced", RBACConfig{Mode: "namespaced", Namespaces: []string{"apps"}}, ""},
+		{"none", RBACConfig{Mode: "none"}, ""},
+		{"cluster role", RBACConfig{Mode: "clusterRole", ClusterRole: "tiller-manager"}, ""},
+		{"cluster role missing name", RBACConfig{Mode: "clusterRole"}, `rbac mode "clusterRole" requires clusterRole to be set`},
+		{"unknown mode", RBACConfig{Mode: "admin"}, `invalid rbac mode "admin", allowed values are: clusterAdmin, clusterRole, namespaced, none`},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.rbac.Validate()
+			if tc.error == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tc.error)
+			}
+		})
+	}
+}
+
+func TestRBACConfig_Describe(t *testing.T) {
+	tiller := TillerSettings{TillerNamespace: "tenant-a", ServiceAccount: "tiller"}
+
+	assert.Equal(t, "RBAC mode clusterAdmin: binding service account tenant-a:tiller to ClusterRole cluster-admin",
+		RBACConfig{}.Describe(tiller))
+	assert.Equal(t, "RBAC mode clusterRole: binding service account tenant-a:tiller to ClusterRole tiller-manager",
+		RBACConfig{Mode: "clusterRole", ClusterRole: "tiller-manager"}.Describe(tiller))
+	assert.Equal(t, "RBAC mode namespaced: granting service account tenant-a:tiller a Role in namespaces tenant-a, apps",
+		RBACConfig{Mode: "namespaced", Namespaces: []string{"tenant-a", "apps"}}.Describe(tiller))
+	assert.Equal(t, "RBAC mode none: skipping RBAC setup for service account tenant-a:tiller",
+		RBACConfig{Mode: "none"}.Describe(tiller))
+}
+
+func TestMixin_ReconcileTillerRBAC_ClusterAdmin(t *testing.T) {
+	m := NewTestMixin(t)
+
+	err := m.reconcileTillerRBAC(m.KubeClient)
+	require.NoError(t, err)
+
+	_, err = m.KubeClient.CoreV1().ServiceAccounts("kube-system").Get("tiller-deploy", metav1.GetOptions{})
+	require.NoError(t, err, "the service account should have been created")
+
+	binding, err := m.KubeClient.RbacV1().ClusterRoleBindings().Get("tiller-deploy", metav1.GetOptions{})
+	require.NoError(t, err, "the cluster role binding should have been created")
+	assert.Equal(t, "cluster-admin", binding.RoleRef.Name)
+	require.Len(t, binding.Subjects, 1)
+	assert.Equal(t, rbacv1.Subject{Kind: "ServiceAccount", Name: "tiller-deploy", Namespace: "kube-system"}, binding.Subjects[0])
+
+	// Running again should be a no-op
+	err = m.reconcileTillerRBAC(m.KubeClient)
+	require.NoError(t, err)
+}
+
+func TestMixin_ReconcileTillerRBAC_Namespaced(t *testing.T) {
+	m := NewTestMixin(t)
+	m.Tiller = TillerSettings{TillerNamespace: "tenant-a", ServiceAccount: "tiller"}
+	m.Config.RBAC = RBACConfig{Mode: rbacModeNamespaced, Namespaces: []string{"apps"}}
+
+	err := m.reconcileTillerRBAC(m.KubeClient)
+	require.NoError(t, err)
+
+	for _, ns := range []string{"tenant-a", "apps"} {
+		_, err = m.KubeClient.RbacV1().Roles(ns).Get("tenant-a-tiller", metav1.GetOptions{})
+		require.NoError(t, err, "the role should have been created in namespace %s", ns)
+
+		binding, err := m.KubeClient.RbacV1().RoleBindings(ns).Get("tenant-a-tiller", metav1.GetOptions{})
+		require.NoError(t, err, "the role binding should have been created in namespace %s", ns)
+		assert.Equal(t, "Role", binding.RoleRef.Kind)
+	}
+
+	bindings, err := m.KubeClient.RbacV1().ClusterRoleBindings().List(metav1.ListOptions{})
+	require.NoError(t, err)
+	assert.Empty(t, bindings.Items, "namespaced mode should not create cluster role bindings")
+}
+
+func TestMixin_ReconcileTillerRBAC_RoleRefDrift(t *testing.T) {
+	m := NewTestMixin(t)
+	m.Config.RBAC = RBACConfig{Mode: rbacModeClusterRole, ClusterRole: "tiller-manager"}
+
+	existing := &rbacv1.ClusterRoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: "tiller-deploy"},
+		RoleRef:    rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "ClusterRole", Name: "cluster-admin"},
+	}
+	_, err := m.KubeClient.RbacV1().ClusterRoleBindings().Create(existing)
+	require.NoError(t, err)
+
+	err = m.reconcileTillerRBAC(m.KubeClient)
+	require.NoError(t, err)
+
+	binding, err := m.KubeClient.RbacV1().ClusterRoleBindings().Get("tiller-deploy", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "tiller-manager", binding.RoleRef.Name, "the binding should have been replaced")
+	assert.Len(t, binding.Subjects, 1)
+	assert.Contains(t, m.TestContext.GetOutput(), "ClusterRoleBinding tiller-deploy is bound to ClusterRole cluster-admin, replacing it")
+}
+
+func TestMixin_ReconcileTillerRBAC_Forbidden(t *testing.T) {
+	m := NewTestMixin(t)
+	m.KubeClient.PrependReactor("create", "serviceaccounts", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, apierrors.NewForbidden(schema.GroupResource{Resource: "serviceaccounts"}, "tiller-deploy", errors.New("not allowed"))
+	})
+
+	err := m.reconcileTillerRBAC(m.KubeClient)
+	require.Error(t, err)
+
+	rbacErr, ok := err.(RBACError)
+	require.True(t, ok, "expected an RBACError, got %T", err)
+	assert.Equal(t, "ServiceAccount", rbacErr.Kind)
+	assert.Equal(t, "kube-system", rbacErr.Namespace)
+	assert.True(t, apierrors.IsForbidden(errors.Cause(err)))
+}
@@ -0,0 +1,146 @@
+package helm2
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// envVarName matches the names of environment variables that credentials are read from
+var envVarName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// repositoryName matches the names of repositories, which are also the prefix of
+// the charts in them, such as stable/mysql
+var repositoryName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]*$`)
+
+// sortedRepositoryNames returns the names of the repositories in order, so that
+// they are always added in the same order.
+func sortedRepositoryNames(repos map[string]Repository) []string {
+	names := make([]string, 0, len(repos))
+	for name := range repos {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// validateRepositories checks that each repository has a valid name and URL, that
+// the credentials of authenticated repositories are complete, and that no two
+// repositories have the same URL.
+func validateRepositories(repos map[string]Repository) error {
+	urls := make(map[string]string, len(repos))
+	for _, name := range sortedRepositoryNames(repos) {
+		repo := repos[name]
+		if !repositoryName.MatchString(name) {
+			return errors.Errorf("invalid repository name %q, it may only contain letters, numbers, '.', '-' and '_'", name)
+		}
+		if repo.URL == "" {
+			return errors.Errorf("repository %s: url must be supplied", name)
+		}
+		u, err := url.Parse(repo.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return errors.Errorf("repository %s: invalid url %q, it must be an http or https URL", name, repo.URL)
+		}
+
+		key := strings.TrimSuffix(repo.URL, "/")
+		if other, ok := urls[key]; ok {
+			return errors.Errorf("repositories %s and %s have the same url %s", other, name, repo.URL)
+		}
+		urls[key] = name
+
+		if repo.IsAuthenticated() {
+			err = repo.validateCredentials(name)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// IsAuthenticated returns whether connecting to the repository requires credentials,
+// which are only available when the bundle runs.
+func (r Repository) IsAuthenticated() bool {
+	return r.UsernameEnv != "" || r.PasswordEnv != "" || r.CAFile != "" || r.CertFile != "" || r.KeyFile != ""
+}
+
+// validateCredentials checks that the credentials of an authenticated repository
+// are complete.
+func (r Repository) validateCredentials(name string) error {
+	if r.URL == "" {
+		return errors.Errorf("repository %s: url must be supplied", name)
+	}
+	if (r.UsernameEnv == "") != (r.PasswordEnv == "") {
+		return errors.Errorf("repository %s: usernameEnv and passwordEnv must be set together", name)
+	}
+	for _, env := range []string{r.UsernameEnv, r.PasswordEnv} {
+		if env != "" && !envVarName.MatchString(env) {
+			return errors.Errorf("repository %s: %q is not a valid environment variable name", name, env)
+		}
+	}
+	if (r.CertFile == "") != (r.KeyFile == "") {
+		return errors.Errorf("repository %s: certFile and keyFile must be set together", name)
+	}
+	return nil
+}
+
+// repoAddArgs returns the arguments to helm repo add for the repository, reading
+// its credentials from the environment.
+func (r Repository) repoAddArgs(name string) ([]string, error) {
+	args := []string{"repo", "add", name, r.URL}
+
+	if r.UsernameEnv != "" {
+		username, ok := os.LookupEnv(r.UsernameEnv)
+		if !ok {
+			return nil, errors.Errorf("the username for repository %s is not set, check that a bundle credential is mapped to the environment variable %s",
+				name, r.UsernameEnv)
+		}
+		password, ok := os.LookupEnv(r.PasswordEnv)
+		if !ok {
+			return nil, errors.Errorf("the password for repository %s is not set, check that a bundle credential is mapped to the environment variable %s",
+				name, r.PasswordEnv)
+		}
+		args = append(args, "--username", username, "--password", password)
+	}
+	if r.CAFile != "" {
+		args = append(args, "--ca-file", r.CAFile)
+	}
+	if r.CertFile != "" {
+		args = append(args, "--cert-file", r.CertFile, "--key-file", r.KeyFile)
+	}
+	return args, nil
+}
+
+// addAuthenticatedRepositories adds the repositories that could not be added when
+// the invocation image was built, because they require credentials.
+func (m *Mixin) addAuthenticatedRepositories() error {
+	repos := m.Config.AuthenticatedRepositories
+	for _, name := range sortedRepositoryNames(repos) {
+		repo := repos[name]
+		args, err := repo.repoAddArgs(name)
+		if err != nil {
+			return err
+		}
+
+		// The command is not printed because it contains the credentials
+		fmt.Fprintf(m.Out, "Adding repository %s from %s\n", name, repo.URL)
+		cmd := m.newHelmCommand(args...)
+		cmd.Stdout = m.Out
+		cmd.Stderr = m.Err
+
+		err = cmd.Start()
+		if err != nil {
+			return errors.Wrapf(err, "could not add repository %s", name)
+		}
+		err = cmd.Wait()
+		if err != nil {
+			return errors.Wrapf(err, "could not add repository %s", name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+package helm2
+
+import (
+	"bytes"
+	"os"
+	"testing"
+
+	"get.porter.sh/porter/pkg/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	yaml "gopkg.in/yaml.v2"
+)
+
+func TestRepository_ValidateCredentials(t *testing.T) {
+	testcases := map[string]struct {
+		repo    Repository
+		wantErr string
+	}{
+		"basic auth":       {repo: Repository{URL: "https://charts.example.com", UsernameEnv: "USER", PasswordEnv: "PASSWORD"}},
+		"client cert":      {repo: Repository{URL: "https://charts.example.com", CAFile: "/cnab/app/ca.pem", CertFile: "/cnab/app/cert.pem", KeyFile: "/cnab/app/key.pem"}},
+		"missing url":      {repo: Repository{UsernameEnv: "USER", PasswordEnv: "PASSWORD"}, wantErr: "repository private: url must be supplied"},
+		"missing password": {repo: Repository{URL: "https://charts.example.com", UsernameEnv: "USER"}, wantErr: "repository private: usernameEnv and passwordEnv must be set together"},
+		"invalid env":      {repo: Repository{URL: "https://charts.example.com", UsernameEnv: "USER", PasswordEnv: "{{ bundle.credentials.password }}"}, wantErr: `repository private: "{{ bundle.credentials.password }}" is not a valid environment variable name`},
+		"missing key":      {repo: Repository{URL: "https://charts.example.com", CertFile: "/cnab/app/cert.pem"}, wantErr: "repository private: certFile and keyFile must be set together"},
+	}
+	for name, tc := range testcases {
+		t.Run(name, func(t *testing.T) {
+			require.True(t, tc.repo.IsAuthenticated())
+			err := tc.repo.validateCredentials("private")
+			if tc.wantErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tc.wantErr)
+			}
+		})
+	}
+
+	assert.False(t, Repository{URL: "https://charts.helm.sh/stable"}.IsAuthenticated())
+}
+
+func TestValidateRepositories(t *testing.T) {
+	testcases := map[string]struct {
+		repos   map[string]Repository
+		wantErr string
+	}{
+		"valid":         {repos: map[string]Repository{"stable": {URL: "https://charts.helm.sh/stable"}, "bitnami": {URL: "https://charts.bitnami.com/bitnami"}}},
+		"invalid name":  {repos: map[string]Repository{"my/repo": {URL: "https://charts.example.com"}}, wantErr: `invalid repository name "my/repo", it may only contain letters, numbers, '.', '-' and '_'`},
+		"missing url":   {repos: map[string]Repository{"stable": {}}, wantErr: "repository stable: url must be supplied"},
+		"invalid url":   {repos: map[string]Repository{"stable": {URL: "stable-charts"}}, wantErr: `repository stable: invalid url "stable-charts", it must be an http or https URL`},
+		"duplicate url": {repos: map[string]Repository{"stable": {URL: "https://charts.helm.sh/stable"}, "legacy": {URL: "https://charts.helm.sh/stable"}}, wantErr: "repositories legacy and stable have the same url https://charts.helm.sh/stable"},
+		"credentials":   {repos: map[string]Repository{"private": {URL: "https://charts.example.com", UsernameEnv: "USER"}}, wantErr: "repository private: usernameEnv and passwordEnv must be set together"},
+	}
+	for name, tc := range testcases {
+		t.Run(name, func(t *testing.T) {
+			err := validateRepositories(tc.repos)
+			if tc.wantErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestRepository_RepoAddArgs(t *testing.T) {
+	repo := Repository{URL: "https://charts.example.com", UsernameEnv: "CHARTS_USERNAME", PasswordEnv: "CHARTS_PASSWORD",
+		CertFile: "/cnab/app/cert.pem", KeyFile: "/cnab/app/key.pem"}
+
+	_, err := repo.repoAddArgs("private")
+	assert.EqualError(t, err, "the username for repository private is not set, check that a bundle credential is mapped to the environment variable CHARTS_USERNAME")
+
+	os.Setenv("CHARTS_USERNAME", "porter")
+	defer os.Unsetenv("CHARTS_USERNAME")
+	os.Setenv("CHARTS_PASSWORD", "s3cret")
+	defer os.Unsetenv("CHARTS_PASSWORD")
+
+	args, err := repo.repoAddArgs("private")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"repo", "add", "private", "https://charts.example.com", "--username", "porter", "--password", "s3cret",
+		"--cert-file", "/cnab/app/cert.pem", "--key-file", "/cnab/app/key.pem"}, args)
+}
+
+func TestMixin_Install_AuthenticatedRepository(t *testing.T) {
+	os.Setenv(test.ExpectedCommandEnv, "helm repo add private https://charts.example.com --username porter --password s3cret --ca-file /cnab/app/ca.pem\n"+
+		"helm install --name mysql private/mysql")
+	defer os.Unsetenv(test.ExpectedCommandEnv)
+	os.Setenv("CHARTS_USERNAME", "porter")
+	defer os.Unsetenv("CHARTS_USERNAME")
+	os.Setenv("CHARTS_PASSWORD", "s3cret")
+	defer os.Unsetenv("CHARTS_PASSWORD")
+
+	h := NewTestMixin(t)
+	cfg := RuntimeConfig{AuthenticatedRepositories: map[string]Repository{
+		"private": {URL: "https://charts.example.com", UsernameEnv: "CHARTS_USERNAME", PasswordEnv: "CHARTS_PASSWORD", CAFile: "/cnab/app/ca.pem"},
+	}}
+	b, err := yaml.Marshal(cfg)
+	require.NoError(t, err)
+	err = h.FileSystem.WriteFile(runtimeConfigPath, b, 0644)
+	require.NoError(t, err)
+
+	action := InstallAction{Steps: []InstallStep{{InstallArguments: InstallArguments{
+		Step:  Step{Description: "Install MySQL"},
+		Name:  "mysql",
+		Chart: "private/mysql",
+	}}}}
+	b, err = yaml.Marshal(action)
+	require.NoError(t, err)
+	h.In = bytes.NewReader(b)
+
+	err = h.Install()
+	require.NoError(t, err)
+	assert.Contains(t, h.TestContext.GetOutput(), "Adding repository private from https://charts.example.com\n")
+	assert.NotContains(t, h.TestContext.GetOutput(), "s3cret", "the credentials should not be printed")
+}
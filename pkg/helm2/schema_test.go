@@ -30,6 +30,7 @@ func TestMixin_ValidatePayload(t *testing.T) {
 		error string
 	}{
 		{"install", "testdata/install-input.yaml", true, ""},
+		{"install.inline-values", "testdata/install-input-with-inline-values.yaml", true, ""},
 		{"execute", "testdata/execute-input.yaml", true, ""},
 		{"upgrade", "testdata/upgrade-input.yaml", true, ""},
 		{"uninstall", "testdata/uninstall-input.yaml", true, ""},
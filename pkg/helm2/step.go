@@ -1,5 +1,9 @@
 package helm2
 
+import (
+	"get.porter.sh/porter/pkg/exec/builder"
+)
+
 type Step struct {
 	Description string       `yaml:"description"`
 	Outputs     []HelmOutput `yaml:"outputs,omitempty"`
@@ -14,3 +18,127 @@ type HelmOutput struct {
 	Namespace    string `yaml:"namespace,omitempty"`
 	JSONPath     string `yaml:"jsonPath,omitempty"`
 }
+
+// KubernetesSettings select the cluster that a step talks to, both from the
+// mixin's kubernetes client and from the helm and kubectl commands it runs.
+type KubernetesSettings struct {
+	KubeConfig  string `yaml:"kubeconfig,omitempty"`
+	KubeContext string `yaml:"kubeContext,omitempty"`
+	InCluster   bool   `yaml:"inCluster,omitempty"`
+}
+
+const (
+	defaultTillerNamespace      string = "kube-system"
+	defaultTillerServiceAccount string = "tiller-deploy"
+)
+
+// TillerSettings select the Tiller that helm commands talk to. They may be set
+// in the mixin configuration and overridden by each step.
+type TillerSettings struct {
+	TillerNamespace string `yaml:"tillerNamespace,omitempty"`
+	ServiceAccount  string `yaml:"serviceAccount,omitempty"`
+
+	// TLS settings for a Tiller secured with mutual TLS. The certificates are
+	// paths, usually where the bundle's credentials are mounted. TLS and TLSVerify
+	// are pointers so that a step can turn off a setting from the mixin configuration.
+	TLS         *bool  `yaml:"tls,omitempty"`
+	TLSVerify   *bool  `yaml:"tlsVerify,omitempty"`
+	TLSCACert   string `yaml:"tlsCaCert,omitempty"`
+	TLSCert     string `yaml:"tlsCert,omitempty"`
+	TLSKey      string `yaml:"tlsKey,omitempty"`
+	TLSHostname string `yaml:"tlsHostname,omitempty"`
+}
+
+// GetTillerNamespace returns the namespace that Tiller runs in.
+func (s TillerSettings) GetTillerNamespace() string {
+	if s.TillerNamespace == "" {
+		return defaultTillerNamespace
+	}
+	return s.TillerNamespace
+}
+
+// GetServiceAccount returns the name of the service account that Tiller runs as.
+func (s TillerSettings) GetServiceAccount() string {
+	if s.ServiceAccount == "" {
+		return defaultTillerServiceAccount
+	}
+	return s.ServiceAccount
+}
+
+// Merge returns a copy of the settings with any values set in overrides applied.
+func (s TillerSettings) Merge(overrides TillerSettings) TillerSettings {
+	if overrides.TillerNamespace != "" {
+		s.TillerNamespace = overrides.TillerNamespace
+	}
+	if overrides.ServiceAccount != "" {
+		s.ServiceAccount = overrides.ServiceAccount
+	}
+	if overrides.TLS != nil {
+		s.TLS = overrides.TLS
+	}
+	if overrides.TLSVerify != nil {
+		s.TLSVerify = overrides.TLSVerify
+	}
+	if overrides.TLSCACert != "" {
+		s.TLSCACert = overrides.TLSCACert
+	}
+	if overrides.TLSCert != "" {
+		s.TLSCert = overrides.TLSCert
+	}
+	if overrides.TLSKey != "" {
+		s.TLSKey = overrides.TLSKey
+	}
+	if overrides.TLSHostname != "" {
+		s.TLSHostname = overrides.TLSHostname
+	}
+	return s
+}
+
+// TLSEnabled returns true when helm must use TLS to talk to Tiller.
+func (s TillerSettings) TLSEnabled() bool {
+	if s.TLS != nil {
+		return *s.TLS
+	}
+	// Helm implies --tls when --tls-verify is set
+	return s.VerifyTLS()
+}
+
+// VerifyTLS returns true when helm must verify Tiller's certificate.
+func (s TillerSettings) VerifyTLS() bool {
+	return s.TLSVerify != nil && *s.TLSVerify
+}
+
+// TLSFlags returns the flags for helm commands that talk to Tiller.
+func (s TillerSettings) TLSFlags() []string {
+	var flags []string
+	for _, flag := range s.tlsFlags() {
+		flags = append(flags, "--"+flag.Name)
+		flags = append(flags, flag.Values...)
+	}
+	return flags
+}
+
+// tlsFlags returns the TLS flags for the helm commands in invoke steps.
+func (s TillerSettings) tlsFlags() builder.Flags {
+	if !s.TLSEnabled() {
+		return nil
+	}
+
+	flags := builder.Flags{builder.NewFlag("tls")}
+	if s.VerifyTLS() {
+		flags = append(flags, builder.NewFlag("tls-verify"))
+	}
+	if s.TLSCACert != "" {
+		flags = append(flags, builder.NewFlag("tls-ca-cert", s.TLSCACert))
+	}
+	if s.TLSCert != "" {
+		flags = append(flags, builder.NewFlag("tls-cert", s.TLSCert))
+	}
+	if s.TLSKey != "" {
+		flags = append(flags, builder.NewFlag("tls-key", s.TLSKey))
+	}
+	if s.TLSHostname != "" {
+		flags = append(flags, builder.NewFlag("tls-hostname", s.TLSHostname))
+	}
+	return flags
+}
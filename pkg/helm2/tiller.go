@@ -0,0 +1,312 @@
+package helm2
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/Masterminds/semver"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/kubernetes"
+)
+
+// tillerDeploymentName is the name of the deployment created by helm init
+const tillerDeploymentName string = "tiller-deploy"
+
+// tillerContainerName is the name of Tiller's container in the deployment
+const tillerContainerName string = "tiller"
+
+// How the mixin manages Tiller before each step
+const (
+	// tillerManageTrue installs Tiller when it is missing, and matches the client to its version
+	tillerManageTrue string = "true"
+
+	// tillerManageFalse never installs Tiller, and fails when it is not ready
+	tillerManageFalse string = "false"
+
+	// tillerManageVerifyOnly checks that Tiller is ready and compatible, without changing anything
+	tillerManageVerifyOnly string = "verify-only"
+)
+
+// How the mixin resolves a difference between the versions of Tiller and the helm client
+const (
+	// versionPolicyMatchTiller switches to a helm client that matches Tiller
+	versionPolicyMatchTiller string = "matchTiller"
+
+	// versionPolicyUpgradeTiller upgrades Tiller to the version of the helm client
+	versionPolicyUpgradeTiller string = "upgradeTiller"
+
+	// versionPolicyFail stops the step when the versions differ
+	versionPolicyFail string = "fail"
+)
+
+// The storage backends that Tiller can keep release information in
+const (
+	tillerStorageConfigMap string = "configmap"
+	tillerStorageSecret    string = "secret"
+)
+
+// TillerConfig controls how the mixin bootstraps Tiller.
+type TillerConfig struct {
+	// Manage is true (default), false or verify-only
+	Manage string `yaml:"manage,omitempty"`
+
+	// VersionPolicy is matchTiller (default), upgradeTiller or fail
+	VersionPolicy string `yaml:"versionPolicy,omitempty"`
+
+	// HistoryMax limits the number of revisions that Tiller keeps for each release, 0 keeps them all
+	HistoryMax int `yaml:"historyMax,omitempty"`
+
+	// Storage is where Tiller keeps release information, configmap (default) or secret
+	Storage string `yaml:"storage,omitempty"`
+
+	// NodeSelectors restricts the nodes that Tiller may be scheduled on
+	NodeSelectors map[string]string `yaml:"nodeSelectors,omitempty"`
+
+	// Image overrides the Tiller image, for example to use a private registry
+	Image string `yaml:"image,omitempty"`
+
+	// ConnectionTimeout is the number of seconds that helm waits to establish a connection to Tiller
+	ConnectionTimeout int `yaml:"connectionTimeout,omitempty"`
+}
+
+// GetManage returns how the mixin manages Tiller, defaulting to true.
+func (c TillerConfig) GetManage() string {
+	if c.Manage == "" {
+		return tillerManageTrue
+	}
+	return c.Manage
+}
+
+// GetVersionPolicy returns how version differences are resolved, defaulting to matchTiller.
+func (c TillerConfig) GetVersionPolicy() string {
+	if c.VersionPolicy == "" {
+		return versionPolicyMatchTiller
+	}
+	return c.VersionPolicy
+}
+
+// Validate checks that the management mode and version policy are known, and
+// that the policy does not require changes to a Tiller that the mixin doesn't manage.
+func (c TillerConfig) Validate() error {
+	switch c.GetManage() {
+	case tillerManageTrue, tillerManageFalse, tillerManageVerifyOnly:
+	default:
+		return errors.Errorf("invalid tiller manage mode %q, allowed values are: %s", c.Manage,
+			strings.Join([]string{tillerManageTrue, tillerManageFalse, tillerManageVerifyOnly}, ", "))
+	}
+
+	switch c.GetVersionPolicy() {
+	case versionPolicyMatchTiller, versionPolicyFail:
+	case versionPolicyUpgradeTiller:
+		if c.GetManage() != tillerManageTrue {
+			return errors.Errorf("tiller versionPolicy %q requires tiller manage mode %q", versionPolicyUpgradeTiller, tillerManageTrue)
+		}
+	default:
+		return errors.Errorf("invalid tiller versionPolicy %q, allowed values are: %s", c.VersionPolicy,
+			strings.Join([]string{versionPolicyMatchTiller, versionPolicyUpgradeTiller, versionPolicyFail}, ", "))
+	}
+
+	return c.validateInitSettings()
+}
+
+// validateInitSettings checks the settings that are passed to helm init.
+func (c TillerConfig) validateInitSettings() error {
+	if c.HistoryMax < 0 {
+		return errors.Errorf("invalid tiller historyMax %d, it must not be negative", c.HistoryMax)
+	}
+
+	switch c.Storage {
+	case "", tillerStorageConfigMap, tillerStorageSecret:
+	default:
+		return errors.Errorf("invalid tiller storage %q, allowed values are: %s", c.Storage,
+			strings.Join([]string{tillerStorageConfigMap, tillerStorageSecret}, ", "))
+	}
+
+	for key, value := range c.NodeSelectors {
+		if key == "" || strings.ContainsAny(key, "=, ") || strings.ContainsAny(value, "=, ") {
+			return errors.Errorf("invalid tiller nodeSelector %q: %q, the label and value may not be empty or contain '=', ',' or spaces", key, value)
+		}
+	}
+
+	if strings.ContainsAny(c.Image, " \t\n") {
+		return errors.Errorf("invalid tiller image %q, it may not contain whitespace", c.Image)
+	}
+
+	if c.ConnectionTimeout < 0 {
+		return errors.Errorf("invalid tiller connectionTimeout %d, it must not be negative", c.ConnectionTimeout)
+	}
+	return nil
+}
+
+// InitFlags returns the helm init flags for the Tiller settings that are not left to helm's defaults.
+func (c TillerConfig) InitFlags() []string {
+	var flags []string
+	if c.HistoryMax > 0 {
+		flags = append(flags, "--history-max", strconv.Itoa(c.HistoryMax))
+	}
+	if c.Storage != "" && c.Storage != tillerStorageConfigMap {
+		flags = append(flags, "--override", "storage="+c.Storage)
+	}
+	if len(c.NodeSelectors) > 0 {
+		selectors := make([]string, 0, len(c.NodeSelectors))
+		for key, value := range c.NodeSelectors {
+			selectors = append(selectors, key+"="+value)
+		}
+		sort.Strings(selectors)
+		flags = append(flags, "--node-selectors", strings.Join(selectors, ","))
+	}
+	if c.Image != "" {
+		flags = append(flags, "--tiller-image", c.Image)
+	}
+	if c.ConnectionTimeout > 0 {
+		flags = append(flags, "--tiller-connection-timeout", strconv.Itoa(c.ConnectionTimeout))
+	}
+	return flags
+}
+
+// TillerState describes whether Tiller can accept connections
+type TillerState int
+
+const (
+	// TillerAbsent means that Tiller is not installed
+	TillerAbsent TillerState = iota
+
+	// TillerNotReady means that Tiller is installed but none of its pods are ready
+	TillerNotReady
+
+	// TillerReady means that Tiller is installed and at least one of its pods is ready
+	TillerReady
+)
+
+func (s TillerState) String() string {
+	switch s {
+	case TillerAbsent:
+		return "absent"
+	case TillerNotReady:
+		return "not-ready"
+	case TillerReady:
+		return "ready"
+	default:
+		return "unknown"
+	}
+}
+
+// TillerStatus is the state of Tiller in the cluster
+type TillerStatus struct {
+	State TillerState
+
+	// Image that Tiller runs, empty when Tiller is absent
+	Image string
+
+	// Version of Tiller from its image tag, empty when it could not be determined
+	Version string
+
+	// ReadyReplicas is the number of Tiller pods that are ready
+	ReadyReplicas int
+}
+
+// getTillerStatus finds Tiller's deployment in the namespace and reports how
+// many of its pods are ready and which version they run.
+func getTillerStatus(client kubernetes.Interface, namespace string) (TillerStatus, error) {
+	deploy, err := client.AppsV1().Deployments(namespace).Get(tillerDeploymentName, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return TillerStatus{State: TillerAbsent}, nil
+	}
+	if err != nil {
+		return TillerStatus{}, errors.Wrapf(err, "could not retrieve the Tiller deployment from namespace %s", namespace)
+	}
+
+	status := TillerStatus{
+		State: TillerNotReady,
+		Image: tillerImage(deploy.Spec.Template.Spec),
+	}
+
+	selector := labels.Everything()
+	if deploy.Spec.Selector != nil {
+		selector = labels.SelectorFromSet(deploy.Spec.Selector.MatchLabels)
+	}
+	pods, err := client.CoreV1().Pods(namespace).List(metav1.ListOptions{LabelSelector: selector.String()})
+	if err != nil {
+		return TillerStatus{}, errors.Wrapf(err, "could not list the Tiller pods in namespace %s", namespace)
+	}
+
+	for _, pod := range pods.Items {
+		if !isPodReady(pod) {
+			continue
+		}
+		status.ReadyReplicas++
+		// Prefer the image that is running over the deployment's, which may be mid-rollout
+		status.Image = tillerImage(pod.Spec)
+	}
+
+	if status.ReadyReplicas > 0 {
+		status.State = TillerReady
+	}
+	status.Version = imageTag(status.Image)
+	return status, nil
+}
+
+// tillerImage returns the image of the Tiller container in the pod spec.
+func tillerImage(spec corev1.PodSpec) string {
+	for _, c := range spec.Containers {
+		if c.Name == tillerContainerName {
+			return c.Image
+		}
+	}
+	if len(spec.Containers) > 0 {
+		return spec.Containers[0].Image
+	}
+	return ""
+}
+
+// imageTag returns the tag of an image reference, or an empty string when
+// the image is referenced by digest or has no tag.
+func imageTag(image string) string {
+	if strings.Contains(image, "@") {
+		return ""
+	}
+	i := strings.LastIndex(image, ":")
+	if i < 0 || strings.Contains(image[i:], "/") {
+		return ""
+	}
+	return image[i+1:]
+}
+
+func isPodReady(pod corev1.Pod) bool {
+	if pod.DeletionTimestamp != nil || pod.Status.Phase != corev1.PodRunning {
+		return false
+	}
+	for _, c := range pod.Status.Conditions {
+		if c.Type == corev1.PodReady {
+			return c.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
+
+// isTillerCompatible checks that the helm client can talk to Tiller. Helm 2
+// requires the client and Tiller to have the same major and minor version.
+func isTillerCompatible(clientVersion, tillerVersion string) (bool, error) {
+	c, err := semver.NewVersion(clientVersion)
+	if err != nil {
+		return false, errors.Wrapf(err, "client version %q cannot be parsed as semver", clientVersion)
+	}
+	t, err := semver.NewVersion(tillerVersion)
+	if err != nil {
+		return false, errors.Wrapf(err, "Tiller version %q cannot be parsed as semver", tillerVersion)
+	}
+	return c.Major() == t.Major() && c.Minor() == t.Minor(), nil
+}
+
+// unmanagedTillerError explains why the mixin can't continue when it is not
+// allowed to install Tiller.
+func unmanagedTillerError(status TillerStatus, namespace string, manage string) error {
+	return errors.Errorf("Tiller is %s in namespace %s and the mixin is configured with tiller.manage: %s, so it will not install it. "+
+		"Install Tiller in that namespace, check that its pods are ready, or remove the setting to let the mixin manage Tiller",
+		status.State, namespace, manage)
+}
@@ -0,0 +1,175 @@
+package helm2
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	testclient "k8s.io/client-go/kubernetes/fake"
+)
+
+var tillerLabels = map[string]string{"app": "helm", "name": "tiller"}
+
+func newTillerDeployment(namespace string, image string) *appsv1.Deployment {
+	return &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: tillerDeploymentName, Namespace: namespace},
+		Spec: appsv1.DeploymentSpec{
+			Selector: &metav1.LabelSelector{MatchLabels: tillerLabels},
+			Template: corev1.PodTemplateSpec{
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{{Name: tillerContainerName, Image: image}},
+				},
+			},
+		},
+	}
+}
+
+func newTillerPod(namespace string, name string, image string, ready bool) *corev1.Pod {
+	readyStatus := corev1.ConditionFalse
+	if ready {
+		readyStatus = corev1.ConditionTrue
+	}
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace, Labels: tillerLabels},
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{{Name: tillerContainerName, Image: image}},
+		},
+		Status: corev1.PodStatus{
+			Phase:      corev1.PodRunning,
+			Conditions: []corev1.PodCondition{{Type: corev1.PodReady, Status: readyStatus}},
+		},
+	}
+}
+
+func TestGetTillerStatus(t *testing.T) {
+	t.Run("absent", func(t *testing.T) {
+		client := testclient.NewSimpleClientset()
+
+		status, err := getTillerStatus(client, "kube-system")
+		require.NoError(t, err)
+		assert.Equal(t, TillerAbsent, status.State)
+	})
+
+	t.Run("not ready", func(t *testing.T) {
+		client := testclient.NewSimpleClientset(
+			newTillerDeployment("kube-system", "gcr.io/kubernetes-helm/tiller:v2.16.1"),
+			newTillerPod("kube-system", "tiller-1", "gcr.io/kubernetes-helm/tiller:v2.16.1", false),
+		)
+
+		status, err := getTillerStatus(client, "kube-system")
+		require.NoError(t, err)
+		assert.Equal(t, TillerNotReady, status.State)
+		assert.Equal(t, "v2.16.1", status.Version)
+		assert.Equal(t, 0, status.ReadyReplicas)
+	})
+
+	t.Run("ready", func(t *testing.T) {
+		client := testclient.NewSimpleClientset(
+			newTillerDeployment("tenant-a", "gcr.io/kubernetes-helm/tiller:v2.17.0"),
+			newTillerPod("tenant-a", "tiller-1", "gcr.io/kubernetes-helm/tiller:v2.16.1", true),
+			newTillerPod("tenant-a", "tiller-2", "gcr.io/kubernetes-helm/tiller:v2.17.0", false),
+		)
+
+		status, err := getTillerStatus(client, "tenant-a")
+		require.NoError(t, err)
+		assert.Equal(t, TillerReady, status.State)
+		assert.Equal(t, 1, status.ReadyReplicas)
+		assert.Equal(t, "v2.16.1", status.Version, "the version should come from the ready pod")
+	})
+
+	t.Run("other namespace", func(t *testing.T) {
+		client := testclient.NewSimpleClientset(
+			newTillerDeployment("kube-system", "gcr.io/kubernetes-helm/tiller:v2.17.0"),
+		)
+
+		status, err := getTillerStatus(client, "tenant-a")
+		require.NoError(t, err)
+		assert.Equal(t, TillerAbsent, status.State)
+	})
+}
+
+func TestImageTag(t *testing.T) {
+	testcases := map[string]string{
+		"gcr.io/kubernetes-helm/tiller:v2.17.0":         "v2.17.0",
+		"localhost:5000/tiller:v2.16.1":                 "v2.16.1",
+		"localhost:5000/tiller":                         "",
+		"tiller":                                        "",
+		"ghcr.io/helm/tiller@sha256:0123456789abcdef01": "",
+	}
+	for image, want := range testcases {
+		assert.Equal(t, want, imageTag(image), image)
+	}
+}
+
+func TestTillerConfig_Validate(t *testing.T) {
+	for _, manage := range []string{"", "true", "false", "verify-only"} {
+		assert.NoError(t, TillerConfig{Manage: manage}.Validate(), manage)
+	}
+	assert.EqualError(t, TillerConfig{Manage: "never"}.Validate(),
+		`invalid tiller manage mode "never", allowed values are: true, false, verify-only`)
+
+	for _, policy := range []string{"", "matchTiller", "upgradeTiller", "fail"} {
+		assert.NoError(t, TillerConfig{VersionPolicy: policy}.Validate(), policy)
+	}
+	assert.EqualError(t, TillerConfig{VersionPolicy: "newest"}.Validate(),
+		`invalid tiller versionPolicy "newest", allowed values are: matchTiller, upgradeTiller, fail`)
+	assert.EqualError(t, TillerConfig{Manage: "verify-only", VersionPolicy: "upgradeTiller"}.Validate(),
+		`tiller versionPolicy "upgradeTiller" requires tiller manage mode "true"`)
+}
+
+func TestIsTillerCompatible(t *testing.T) {
+	ok, err := isTillerCompatible("v2.17.0", "v2.17.1")
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	ok, err = isTillerCompatible("v2.17.0", "v2.16.0")
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	_, err = isTillerCompatible("v2.17.0", "latest")
+	assert.EqualError(t, err, `Tiller version "latest" cannot be parsed as semver: Invalid Semantic Version`)
+}
+
+func TestTillerConfig_ValidateInitSettings(t *testing.T) {
+	testcases := map[string]struct {
+		config  TillerConfig
+		wantErr string
+	}{
+		"defaults":          {config: TillerConfig{}},
+		"all settings":      {config: TillerConfig{HistoryMax: 10, Storage: "secret", NodeSelectors: map[string]string{"beta.kubernetes.io/os": "linux"}, Image: "registry.example.com/tiller:v2.17.0", ConnectionTimeout: 60}},
+		"negative history":  {config: TillerConfig{HistoryMax: -1}, wantErr: "invalid tiller historyMax -1, it must not be negative"},
+		"unknown storage":   {config: TillerConfig{Storage: "sql"}, wantErr: `invalid tiller storage "sql", allowed values are: configmap, secret`},
+		"bad node selector": {config: TillerConfig{NodeSelectors: map[string]string{"zone": "a,b"}}, wantErr: `invalid tiller nodeSelector "zone": "a,b", the label and value may not be empty or contain '=', ',' or spaces`},
+		"bad image":         {config: TillerConfig{Image: "tiller latest"}, wantErr: `invalid tiller image "tiller latest", it may not contain whitespace`},
+		"negative timeout":  {config: TillerConfig{ConnectionTimeout: -5}, wantErr: "invalid tiller connectionTimeout -5, it must not be negative"},
+	}
+	for name, tc := range testcases {
+		t.Run(name, func(t *testing.T) {
+			err := tc.config.Validate()
+			if tc.wantErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestTillerConfig_InitFlags(t *testing.T) {
+	assert.Empty(t, TillerConfig{Storage: "configmap"}.InitFlags())
+
+	c := TillerConfig{
+		HistoryMax:        10,
+		Storage:           "secret",
+		NodeSelectors:     map[string]string{"kubernetes.io/os": "linux", "agentpool": "system"},
+		Image:             "registry.example.com/tiller:v2.17.0",
+		ConnectionTimeout: 60,
+	}
+	wantFlags := []string{"--history-max", "10", "--override", "storage=secret",
+		"--node-selectors", "agentpool=system,kubernetes.io/os=linux",
+		"--tiller-image", "registry.example.com/tiller:v2.17.0", "--tiller-connection-timeout", "60"}
+	assert.Equal(t, wantFlags, c.InitFlags())
+}
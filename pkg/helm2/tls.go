@@ -0,0 +1,272 @@
+package helm2
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"path/filepath"
+	"time"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// tlsSecretName is the secret in Tiller's namespace that holds the certificates
+// generated by the mixin
+const tlsSecretName string = "helm2-mixin-tiller-tls"
+
+// tlsDir is where the generated certificates are written for helm to use
+const tlsDir string = "/root/.helm/tls"
+
+// defaultTLSHostname is the name on Tiller's generated certificate when tlsHostname is not set
+const defaultTLSHostname string = "tiller-server"
+
+// tlsValidity is how long the generated certificates are valid
+const tlsValidity = 5 * 365 * 24 * time.Hour
+
+// Keys in the TLS secret, also used as the file names in tlsDir
+const (
+	tlsCACertKey     string = "ca.crt"
+	tlsTillerCertKey string = "tiller.crt"
+	tlsTillerKeyKey  string = "tiller.key"
+	tlsClientCertKey string = "client.crt"
+	tlsClientKeyKey  string = "client.key"
+)
+
+// Names of the outputs that return the generated client certificates
+const (
+	tlsCACertOutput     string = "tiller-ca-cert"
+	tlsClientCertOutput string = "helm-client-cert"
+	tlsClientKeyOutput  string = "helm-client-key"
+)
+
+// tlsCertificates are the PEM encoded certificates and keys generated for Tiller and the helm client
+type tlsCertificates map[string][]byte
+
+// generateTLSCertificates creates a self-signed CA, and uses it to sign a
+// certificate for Tiller and a certificate for the helm client.
+func generateTLSCertificates(hostname string) (tlsCertificates, error) {
+	now := time.Now()
+
+	caKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not generate the CA key")
+	}
+	caTmpl, err := newCertificateTemplate("helm2-mixin-ca", now)
+	if err != nil {
+		return nil, err
+	}
+	caTmpl.IsCA = true
+	caTmpl.BasicConstraintsValid = true
+	caTmpl.KeyUsage = x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature
+	caDER, err := x509.CreateCertificate(rand.Reader, caTmpl, caTmpl, &caKey.PublicKey, caKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not create the CA certificate")
+	}
+	ca, err := x509.ParseCertificate(caDER)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not parse the CA certificate")
+	}
+
+	tillerTmpl, err := newCertificateTemplate(hostname, now)
+	if err != nil {
+		return nil, err
+	}
+	// helm connects to Tiller through a port forward on the loopback address
+	tillerTmpl.DNSNames = []string{hostname}
+	tillerTmpl.IPAddresses = []net.IP{net.ParseIP("127.0.0.1")}
+	tillerTmpl.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}
+	tillerCert, tillerKey, err := signCertificate(tillerTmpl, ca, caKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not create the Tiller certificate")
+	}
+
+	clientTmpl, err := newCertificateTemplate("helm-client", now)
+	if err != nil {
+		return nil, err
+	}
+	clientTmpl.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}
+	clientCert, clientKey, err := signCertificate(clientTmpl, ca, caKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not create the helm client certificate")
+	}
+
+	return tlsCertificates{
+		tlsCACertKey:     pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: caDER}),
+		tlsTillerCertKey: tillerCert,
+		tlsTillerKeyKey:  tillerKey,
+		tlsClientCertKey: clientCert,
+		tlsClientKeyKey:  clientKey,
+	}, nil
+}
+
+func newCertificateTemplate(commonName string, now time.Time) (*x509.Certificate, error) {
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, errors.Wrap(err, "could not generate a certificate serial number")
+	}
+
+	return &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    now.Add(-time.Hour),
+		NotAfter:     now.Add(tlsValidity),
+		KeyUsage:     x509.KeyUsageKeyEncipherment | x509.KeyUsageDigitalSignature,
+	}, nil
+}
+
+// signCertificate creates a key and a certificate for the template signed by the CA,
+// returning them PEM encoded.
+func signCertificate(tmpl *x509.Certificate, ca *x509.Certificate, caKey *rsa.PrivateKey) ([]byte, []byte, error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, nil, err
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca, &key.PublicKey, caKey)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	cert := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	return cert, keyPEM, nil
+}
+
+// getTLSCertificates retrieves the certificates previously generated by the mixin.
+func (m *Mixin) getTLSCertificates() (tlsCertificates, bool, error) {
+	client, err := m.getKubernetesClient()
+	if err != nil {
+		return nil, false, errors.Wrap(err, "couldn't get kubernetes client")
+	}
+
+	secret, err := client.CoreV1().Secrets(m.Tiller.GetTillerNamespace()).Get(tlsSecretName, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, errors.Wrapf(err, "could not retrieve the Tiller certificates from secret %s", tlsSecretName)
+	}
+	return tlsCertificates(secret.Data), true, nil
+}
+
+// saveTLSCertificates stores the generated certificates in a secret in Tiller's namespace.
+func (m *Mixin) saveTLSCertificates(certs tlsCertificates) error {
+	client, err := m.getKubernetesClient()
+	if err != nil {
+		return errors.Wrap(err, "couldn't get kubernetes client")
+	}
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      tlsSecretName,
+			Namespace: m.Tiller.GetTillerNamespace(),
+		},
+		Type: corev1.SecretTypeOpaque,
+		Data: certs,
+	}
+	_, err = client.CoreV1().Secrets(secret.Namespace).Create(secret)
+	if err != nil {
+		return errors.Wrapf(err, "could not save the Tiller certificates to secret %s", tlsSecretName)
+	}
+	return nil
+}
+
+// useTLSCertificates writes the generated certificates where helm can read them,
+// configures helm to use them, and returns the client material as outputs.
+func (m *Mixin) useTLSCertificates(certs tlsCertificates) error {
+	err := m.FileSystem.MkdirAll(tlsDir, 0700)
+	if err != nil {
+		return errors.Wrapf(err, "could not create directory %s", tlsDir)
+	}
+	for name, data := range certs {
+		err = m.FileSystem.WriteFile(filepath.Join(tlsDir, name), data, 0600)
+		if err != nil {
+			return errors.Wrapf(err, "could not write %s", name)
+		}
+	}
+
+	enabled := true
+	m.Tiller.TLS = &enabled
+	m.Tiller.TLSVerify = &enabled
+	m.Tiller.TLSCACert = filepath.Join(tlsDir, tlsCACertKey)
+	m.Tiller.TLSCert = filepath.Join(tlsDir, tlsClientCertKey)
+	m.Tiller.TLSKey = filepath.Join(tlsDir, tlsClientKeyKey)
+	if m.Tiller.TLSHostname == "" {
+		m.Tiller.TLSHostname = defaultTLSHostname
+	}
+
+	outputs := map[string]string{
+		tlsCACertOutput:     tlsCACertKey,
+		tlsClientCertOutput: tlsClientCertKey,
+		tlsClientKeyOutput:  tlsClientKeyKey,
+	}
+	for output, key := range outputs {
+		err = m.WriteMixinOutputToFile(output, certs[key])
+		if err != nil {
+			return errors.Wrapf(err, "unable to write output '%s'", output)
+		}
+	}
+	return nil
+}
+
+// loadTLSCertificates configures helm to use the certificates generated by a
+// previous run of the mixin, when the mixin is configured to generate them.
+func (m *Mixin) loadTLSCertificates() error {
+	if !m.Config.TLSGenerate {
+		return nil
+	}
+
+	certs, found, err := m.getTLSCertificates()
+	if err != nil || !found {
+		return err
+	}
+	return m.useTLSCertificates(certs)
+}
+
+// tillerTLSFlags returns the helm init flags that secure a new Tiller with TLS,
+// generating the certificates when the mixin is configured to do so.
+func (m *Mixin) tillerTLSFlags() ([]string, error) {
+	if !m.Config.TLSGenerate {
+		if m.Tiller.TLSEnabled() {
+			return nil, errors.New("the mixin can only install a TLS secured Tiller when tlsGenerate is set, install Tiller before running the bundle or enable tlsGenerate")
+		}
+		return nil, nil
+	}
+
+	certs, found, err := m.getTLSCertificates()
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		hostname := m.Tiller.TLSHostname
+		if hostname == "" {
+			hostname = defaultTLSHostname
+		}
+		certs, err = generateTLSCertificates(hostname)
+		if err != nil {
+			return nil, err
+		}
+		err = m.saveTLSCertificates(certs)
+		if err != nil {
+			return nil, err
+		}
+		err = m.useTLSCertificates(certs)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return []string{
+		"--tiller-tls",
+		"--tiller-tls-verify",
+		"--tiller-tls-cert", filepath.Join(tlsDir, tlsTillerCertKey),
+		"--tiller-tls-key", filepath.Join(tlsDir, tlsTillerKeyKey),
+		"--tls-ca-cert", filepath.Join(tlsDir, tlsCACertKey),
+	}, nil
+}
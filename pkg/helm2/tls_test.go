@@ -0,0 +1,152 @@
+package helm2
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"get.porter.sh/porter/pkg/context"
+	"get.porter.sh/porter/pkg/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func parseCertificate(t *testing.T, data []byte) *x509.Certificate {
+	block, _ := pem.Decode(data)
+	require.NotNil(t, block, "expected a PEM encoded certificate")
+	cert, err := x509.ParseCertificate(block.Bytes)
+	require.NoError(t, err)
+	return cert
+}
+
+func TestGenerateTLSCertificates(t *testing.T) {
+	certs, err := generateTLSCertificates("tiller.example.com")
+	require.NoError(t, err)
+
+	roots := x509.NewCertPool()
+	roots.AddCert(parseCertificate(t, certs[tlsCACertKey]))
+
+	tiller := parseCertificate(t, certs[tlsTillerCertKey])
+	_, err = tiller.Verify(x509.VerifyOptions{
+		DNSName:   "tiller.example.com",
+		Roots:     roots,
+		KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	})
+	assert.NoError(t, err, "the Tiller certificate should be valid for its hostname")
+
+	client := parseCertificate(t, certs[tlsClientCertKey])
+	_, err = client.Verify(x509.VerifyOptions{
+		Roots:     roots,
+		KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	})
+	assert.NoError(t, err, "the client certificate should be valid for client authentication")
+
+	assert.NotEmpty(t, certs[tlsTillerKeyKey])
+	assert.NotEmpty(t, certs[tlsClientKeyKey])
+}
+
+func TestTillerSettings_TLSFlags(t *testing.T) {
+	enabled, disabled := true, false
+	assert.Empty(t, TillerSettings{}.TLSFlags())
+	assert.Equal(t, []string{"--tls"}, TillerSettings{TLS: &enabled}.TLSFlags())
+	assert.Empty(t, TillerSettings{TLS: &disabled, TLSVerify: &enabled}.TLSFlags(), "tls: false should turn off TLS")
+
+	s := TillerSettings{
+		TLSVerify:   &enabled,
+		TLSCACert:   "/cnab/app/ca.pem",
+		TLSCert:     "/cnab/app/cert.pem",
+		TLSKey:      "/cnab/app/key.pem",
+		TLSHostname: "tiller-server",
+	}
+	wantFlags := []string{"--tls", "--tls-verify", "--tls-ca-cert", "/cnab/app/ca.pem",
+		"--tls-cert", "/cnab/app/cert.pem", "--tls-key", "/cnab/app/key.pem", "--tls-hostname", "tiller-server"}
+	assert.Equal(t, wantFlags, s.TLSFlags())
+}
+
+func TestMixin_Init_TLSGenerate(t *testing.T) {
+	os.Setenv(test.ExpectedCommandEnv, "helm init --service-account=tiller-deploy --upgrade --wait "+
+		"--tiller-tls --tiller-tls-verify --tiller-tls-cert /root/.helm/tls/tiller.crt "+
+		"--tiller-tls-key /root/.helm/tls/tiller.key --tls-ca-cert /root/.helm/tls/ca.crt")
+	defer os.Unsetenv(test.ExpectedCommandEnv)
+
+	h := NewTestMixin(t)
+	h.Config.TLSGenerate = true
+
+	initer := NewMockTillerIniter()
+	initer.GetTillerStatus = func(m *Mixin) (TillerStatus, error) {
+		return TillerStatus{State: TillerAbsent}, nil
+	}
+	h.Mixin.TillerIniter = initer
+
+	err := h.Init()
+	require.NoError(t, err)
+
+	secret, err := h.KubeClient.CoreV1().Secrets("kube-system").Get(tlsSecretName, metav1.GetOptions{})
+	require.NoError(t, err, "the generated certificates should be saved in a secret")
+	assert.Contains(t, secret.Data, tlsTillerCertKey)
+
+	for _, output := range []string{tlsCACertOutput, tlsClientCertOutput, tlsClientKeyOutput} {
+		exists, err := h.FileSystem.Exists(filepath.Join(context.MixinOutputsDir, output))
+		require.NoError(t, err)
+		assert.True(t, exists, "expected output %s", output)
+	}
+
+	assert.Contains(t, h.Tiller.TLSFlags(), "--tls-verify")
+	assert.Contains(t, h.Tiller.TLSFlags(), "/root/.helm/tls/client.crt")
+}
+
+func TestMixin_Init_TLSLoadsCertificates(t *testing.T) {
+	h := NewTestMixin(t)
+	h.Config.TLSGenerate = true
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: tlsSecretName, Namespace: "kube-system"},
+		Data: map[string][]byte{
+			tlsCACertKey:     []byte("ca"),
+			tlsClientCertKey: []byte("cert"),
+			tlsClientKeyKey:  []byte("key"),
+		},
+	}
+	_, err := h.KubeClient.CoreV1().Secrets("kube-system").Create(secret)
+	require.NoError(t, err)
+
+	var gotFlags []string
+	initer := NewMockTillerIniter()
+	initer.GetTillerStatus = func(m *Mixin) (TillerStatus, error) {
+		gotFlags = m.Tiller.TLSFlags()
+		return TillerStatus{State: TillerReady, Version: MockHelmClientVersion}, nil
+	}
+	h.Mixin.TillerIniter = initer
+
+	err = h.Init()
+	require.NoError(t, err)
+
+	wantFlags := []string{"--tls", "--tls-verify", "--tls-ca-cert", "/root/.helm/tls/ca.crt",
+		"--tls-cert", "/root/.helm/tls/client.crt", "--tls-key", "/root/.helm/tls/client.key", "--tls-hostname", "tiller-server"}
+	assert.Equal(t, wantFlags, gotFlags, "helm should use the saved certificates to talk to Tiller")
+
+	cert, err := h.FileSystem.ReadFile("/root/.helm/tls/client.crt")
+	require.NoError(t, err)
+	assert.Equal(t, "cert", string(cert))
+}
+
+func TestMixin_Init_TLSWithoutGenerate(t *testing.T) {
+	h := NewTestMixin(t)
+	enabled := true
+	h.Tiller.TLS = &enabled
+	h.Config.RBAC.Mode = rbacModeNone
+
+	initer := NewMockTillerIniter()
+	initer.GetTillerStatus = func(m *Mixin) (TillerStatus, error) {
+		return TillerStatus{State: TillerAbsent}, nil
+	}
+	h.Mixin.TillerIniter = initer
+
+	err := h.Init()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to setup TLS for Tiller")
+}
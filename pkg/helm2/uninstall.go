@@ -22,7 +22,9 @@ type UninstallStep struct {
 
 // UninstallArguments are the arguments available for the Uninstall action
 type UninstallArguments struct {
-	Step `yaml:",inline"`
+	Step               `yaml:",inline"`
+	KubernetesSettings `yaml:",inline"`
+	TillerSettings     `yaml:",inline"`
 
 	Releases []string `yaml:"releases"`
 	Purge    bool     `yaml:"purge"`
@@ -44,6 +46,10 @@ func (m *Mixin) Uninstall() error {
 		return errors.Errorf("expected a single step, but got %d", len(action.Steps))
 	}
 	step := action.Steps[0]
+	err = m.prepareStep(step.KubernetesSettings, step.TillerSettings)
+	if err != nil {
+		return err
+	}
 
 	err = m.Init()
 	if err != nil {
@@ -63,7 +69,8 @@ func (m *Mixin) Uninstall() error {
 }
 
 func (m *Mixin) delete(release string, purge bool) error {
-	cmd := m.NewCommand("helm", "delete")
+	cmd := m.newHelmCommand("delete")
+	cmd.Args = append(cmd.Args, m.Tiller.TLSFlags()...)
 
 	if purge {
 		cmd.Args = append(cmd.Args, "--purge")
@@ -75,7 +82,7 @@ func (m *Mixin) delete(release string, purge bool) error {
 	cmd.Stdout = io.MultiWriter(m.Out, output)
 	cmd.Stderr = io.MultiWriter(m.Err, output)
 
-	prettyCmd := fmt.Sprintf("%s %s", cmd.Path, strings.Join(cmd.Args, " "))
+	prettyCmd := m.prettyCommand(cmd)
 	fmt.Fprintln(m.Out, prettyCmd)
 
 	err := cmd.Start()
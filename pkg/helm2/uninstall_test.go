@@ -59,6 +59,16 @@ func TestMixin_Uninstall(t *testing.T) {
 				},
 			},
 		},
+		{
+			expectedCommand: "helm delete --kube-context mycontext foo\nhelm delete --kube-context mycontext bar",
+			uninstallStep: UninstallStep{
+				UninstallArguments: UninstallArguments{
+					Step:               Step{Description: "Uninstall Foo"},
+					KubernetesSettings: KubernetesSettings{KubeContext: "mycontext"},
+					Releases:           releases,
+				},
+			},
+		},
 	}
 
 	defer os.Unsetenv(test.ExpectedCommandEnv)
@@ -2,8 +2,6 @@ package helm2
 
 import (
 	"fmt"
-	"sort"
-	"strings"
 
 	"github.com/pkg/errors"
 	yaml "gopkg.in/yaml.v2"
@@ -20,17 +18,27 @@ type UpgradeStep struct {
 
 // UpgradeArguments represent the arguments available to the Upgrade step
 type UpgradeArguments struct {
-	Step `yaml:",inline"`
+	Step               `yaml:",inline"`
+	KubernetesSettings `yaml:",inline"`
+	TillerSettings     `yaml:",inline"`
 
 	Namespace   string            `yaml:"namespace"`
 	Name        string            `yaml:"name"`
 	Chart       string            `yaml:"chart"`
 	Version     string            `yaml:"version"`
 	Set         map[string]string `yaml:"set"`
+	SetString   map[string]string `yaml:"setString"`
+	SetFile     map[string]string `yaml:"setFile"`
 	Values      []string          `yaml:"values"`
 	Wait        bool              `yaml:"wait"`
 	ResetValues bool              `yaml:"resetValues"`
 	ReuseValues bool              `yaml:"reuseValues"`
+
+	// ValuesFrom reads values from Secrets and ConfigMaps, and overrides the values files
+	ValuesFrom []ValuesFrom `yaml:"valuesFrom"`
+
+	// InlineValues are nested values that override the values files and ValuesFrom
+	InlineValues map[string]interface{} `yaml:"inlineValues"`
 }
 
 // Upgrade issues a helm upgrade command for a release using the provided UpgradeArguments
@@ -40,11 +48,6 @@ func (m *Mixin) Upgrade() error {
 		return err
 	}
 
-	kubeClient, err := m.getKubernetesClient("/root/.kube/config")
-	if err != nil {
-		return errors.Wrap(err, "couldn't get kubernetes client")
-	}
-
 	var action UpgradeAction
 	err = yaml.Unmarshal(payload, &action)
 	if err != nil {
@@ -54,13 +57,45 @@ func (m *Mixin) Upgrade() error {
 		return errors.Errorf("expected a single step, but got %d", len(action.Steps))
 	}
 	step := action.Steps[0]
+	err = m.prepareStep(step.KubernetesSettings, step.TillerSettings)
+	if err != nil {
+		return err
+	}
+
+	kubeClient, err := m.getKubernetesClient()
+	if err != nil {
+		return errors.Wrap(err, "couldn't get kubernetes client")
+	}
 
 	err = m.Init()
 	if err != nil {
 		return err
 	}
 
-	cmd := m.NewCommand("helm", "upgrade", "--install", step.Name, step.Chart)
+	err = m.addAuthenticatedRepositories()
+	if err != nil {
+		return err
+	}
+
+	chart, err := m.resolveChart(step.Chart, step.Version)
+	if err != nil {
+		return err
+	}
+
+	values, err := getValuesFrom(kubeClient, step.Namespace, step.ValuesFrom)
+	if err != nil {
+		return err
+	}
+	mergeValues(values, step.InlineValues)
+	stepValuesPath, cleanup, err := m.writeStepValues(values)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	cmd := m.newHelmCommand("upgrade", "--install", step.Name, chart)
+
+	cmd.Args = append(cmd.Args, m.Tiller.TLSFlags()...)
 
 	if step.Namespace != "" {
 		cmd.Args = append(cmd.Args, "--namespace", step.Namespace)
@@ -82,25 +117,14 @@ func (m *Mixin) Upgrade() error {
 		cmd.Args = append(cmd.Args, "--wait")
 	}
 
-	for _, v := range step.Values {
-		cmd.Args = append(cmd.Args, "--values", v)
-	}
-
-	// sort the set consistently
-	setKeys := make([]string, 0, len(step.Set))
-	for k := range step.Set {
-		setKeys = append(setKeys, k)
-	}
-	sort.Strings(setKeys)
+	cmd.Args = append(cmd.Args, valuesFlags(step.Values, stepValuesPath)...)
 
-	for _, k := range setKeys {
-		cmd.Args = append(cmd.Args, "--set", fmt.Sprintf("%s=%s", k, step.Set[k]))
-	}
+	cmd.Args = append(cmd.Args, setFlags(step.Set, step.SetString, step.SetFile)...)
 
 	cmd.Stdout = m.Out
 	cmd.Stderr = m.Err
 
-	prettyCmd := fmt.Sprintf("%s %s", cmd.Path, strings.Join(cmd.Args, " "))
+	prettyCmd := m.prettyCommand(cmd)
 	fmt.Fprintln(m.Out, prettyCmd)
 
 	err = cmd.Start()
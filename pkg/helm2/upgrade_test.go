@@ -120,6 +120,23 @@ func TestMixin_Upgrade(t *testing.T) {
 				},
 			},
 		},
+		{
+			expectedCommand: fmt.Sprintf(`%s %s %s %s`, baseUpgrade, baseValues, baseSetArgs,
+				`--set-string build=0123 --set-file config=/cnab/app/files/config.toml`),
+			upgradeStep: UpgradeStep{
+				UpgradeArguments: UpgradeArguments{
+					Step:      Step{Description: "Upgrade Foo"},
+					Namespace: namespace,
+					Name:      name,
+					Chart:     chart,
+					Version:   version,
+					Set:       setArgs,
+					SetString: map[string]string{"build": "0123"},
+					SetFile:   map[string]string{"config": "files/config.toml"},
+					Values:    values,
+				},
+			},
+		},
 	}
 
 	defer os.Unsetenv(test.ExpectedCommandEnv)
@@ -0,0 +1,135 @@
+package helm2
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// stepValuesFile is the name of the temporary values file that holds the values
+// read from the cluster and the inline values of a step
+const stepValuesFile string = "values.yaml"
+
+// writeStepValues writes the values of a step to a temporary values file,
+// returning its path, and a function that removes it once helm has run.
+func (m *Mixin) writeStepValues(values map[string]interface{}) (string, func(), error) {
+	if len(values) == 0 {
+		return "", func() {}, nil
+	}
+
+	b, err := yaml.Marshal(values)
+	if err != nil {
+		return "", nil, errors.Wrap(err, "could not marshal the step values")
+	}
+
+	tmpDir, err := m.FileSystem.TempDir("", "helm2-values")
+	if err != nil {
+		return "", nil, errors.Wrap(err, "unable to create a temporary directory for the step values")
+	}
+	cleanup := func() { m.FileSystem.RemoveAll(tmpDir) }
+
+	// The values may hold secrets, so only the mixin may read them
+	valuesPath := filepath.Join(tmpDir, stepValuesFile)
+	err = m.FileSystem.WriteFile(valuesPath, b, 0600)
+	if err != nil {
+		cleanup()
+		return "", nil, errors.Wrapf(err, "unable to write the step values to %s", valuesPath)
+	}
+	return valuesPath, cleanup, nil
+}
+
+// valuesFlags returns the --values flags for the values files of a step, followed by
+// the step values file, so that its values override the values files.
+func valuesFlags(values []string, stepValuesPath string) []string {
+	flags := make([]string, 0, 2*len(values)+2)
+	for _, v := range values {
+		flags = append(flags, "--values", v)
+	}
+	if stepValuesPath != "" {
+		flags = append(flags, "--values", stepValuesPath)
+	}
+	return flags
+}
+
+// setFlags returns the --set, --set-string and --set-file flags for the values of a
+// step, each sorted by key. Helm applies them in that order, after the values files.
+func setFlags(set, setString, setFile map[string]string) []string {
+	files := make(map[string]string, len(setFile))
+	for k, f := range setFile {
+		files[k] = resolveBundlePath(f)
+	}
+
+	var flags []string
+	flags = append(flags, sortedSetFlags("--set", set)...)
+	flags = append(flags, sortedSetFlags("--set-string", setString)...)
+	flags = append(flags, sortedSetFlags("--set-file", files)...)
+	return flags
+}
+
+// sortedSetFlags returns a flag for each of the values, sorted by key.
+func sortedSetFlags(flag string, values map[string]string) []string {
+	// sort the set consistently
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	flags := make([]string, 0, 2*len(keys))
+	for _, k := range keys {
+		flags = append(flags, flag, fmt.Sprintf("%s=%s", escapeSetKey(k), escapeSetValue(values[k])))
+	}
+	return flags
+}
+
+// resolveBundlePath returns the path of a file in the bundle directory, leaving
+// absolute paths, such as where credentials are mounted, unchanged.
+func resolveBundlePath(p string) string {
+	if path.IsAbs(p) {
+		return p
+	}
+	return path.Join(bundleDir, p)
+}
+
+// escapeSetKey escapes the characters that end a key in helm's --set grammar, so
+// that a key with a comma or equals sign isn't split. Dots and brackets are kept,
+// because they select nested values and list items, and so are the escapes that
+// the key already has, such as the dot in configurationFiles.mysql\.cnf. A trailing
+// backslash is escaped, so that it doesn't escape the equals sign that follows the key.
+func escapeSetKey(key string) string {
+	var b strings.Builder
+	escaped := false
+	for _, r := range key {
+		switch {
+		case escaped:
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case r == ',' || r == '=':
+			b.WriteRune('\\')
+		}
+		b.WriteRune(r)
+	}
+	if escaped {
+		b.WriteRune('\\')
+	}
+	return b.String()
+}
+
+// escapeSetValue escapes a value so that helm's --set grammar reads it back exactly:
+// backslashes and commas are escaped, and a leading brace so it isn't read as a list.
+func escapeSetValue(value string) string {
+	var b strings.Builder
+	for i, r := range value {
+		if r == '\\' || r == ',' || (i == 0 && r == '{') {
+			b.WriteRune('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
@@ -0,0 +1,146 @@
+package helm2
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/helm/pkg/strvals"
+)
+
+func TestSetFlags(t *testing.T) {
+	set := map[string]string{"replicas": "3", "image.tag": "1.0.0"}
+	setString := map[string]string{"build": "0123", "enabled": "true"}
+	setFile := map[string]string{"config": "files/config.toml", "ca": "/cnab/app/credentials/ca.pem"}
+
+	want := []string{
+		"--set", "image.tag=1.0.0",
+		"--set", "replicas=3",
+		"--set-string", "build=0123",
+		"--set-string", "enabled=true",
+		"--set-file", "ca=/cnab/app/credentials/ca.pem",
+		"--set-file", "config=/cnab/app/files/config.toml",
+	}
+	assert.Equal(t, want, setFlags(set, setString, setFile))
+	assert.Empty(t, setFlags(nil, nil, nil))
+}
+
+func TestEscapeSetValue(t *testing.T) {
+	corpus := []string{
+		"",
+		"plain",
+		"p@ss,word",
+		",,,",
+		"jdbc:mysql://db:3306/app?user=admin&password=s3cr=t,ssl=true",
+		"a.b.c",
+		"list[0]",
+		"{a,b}",
+		"not{a,list}",
+		`C:\path\to\file`,
+		`trailing\`,
+		`\,`,
+		"key=value",
+		"spaces and\ttabs",
+		"ünïcødé,☃",
+	}
+	for _, value := range corpus {
+		t.Run(value, func(t *testing.T) {
+			got := map[string]interface{}{}
+			err := strvals.ParseIntoString("key="+escapeSetValue(value), got)
+			require.NoError(t, err)
+			assert.Equal(t, map[string]interface{}{"key": value}, got, "the value should round-trip through helm's --set grammar")
+		})
+	}
+}
+
+func TestEscapeSetKey(t *testing.T) {
+	testcases := map[string]string{
+		"mysqlUser":                         "mysqlUser",
+		"livenessProbe.initialDelaySeconds": "livenessProbe.initialDelaySeconds",
+		"servers[0].port":                   "servers[0].port",
+		`configurationFiles.mysql\.cnf`:     `configurationFiles.mysql\.cnf`,
+		"annotations.a=b":                   `annotations.a\=b`,
+		"labels.x,y":                        `labels.x\,y`,
+		`escaped\,comma`:                    `escaped\,comma`,
+		`trailing\`:                         `trailing\\`,
+	}
+	for key, want := range testcases {
+		assert.Equal(t, want, escapeSetKey(key), key)
+	}
+}
+
+func TestEscapeSetKey_Parse(t *testing.T) {
+	testcases := map[string]map[string]interface{}{
+		"mysqlUser":                     {"mysqlUser": "value"},
+		"servers[0].port":               {"servers": []interface{}{map[string]interface{}{"port": "value"}}},
+		`configurationFiles.mysql\.cnf`: {"configurationFiles": map[string]interface{}{"mysql.cnf": "value"}},
+		"annotations.a=b":               {"annotations": map[string]interface{}{"a=b": "value"}},
+		"labels.x,y":                    {"labels": map[string]interface{}{"x,y": "value"}},
+		`trailing\`:                     {`trailing\`: "value"},
+	}
+	for key, want := range testcases {
+		t.Run(key, func(t *testing.T) {
+			got := map[string]interface{}{}
+			err := strvals.ParseIntoString(escapeSetKey(key)+"=value", got)
+			require.NoError(t, err)
+			assert.Equal(t, want, got, "the key should be read by helm's --set grammar")
+		})
+	}
+}
+
+func TestSetFlags_Escaped(t *testing.T) {
+	set := map[string]string{
+		"db.url":      "jdbc:postgresql://db:5432/app?ssl=true,sslmode=require",
+		"db.password": `p@ss,w0rd\`,
+		"tags":        "{a,b}",
+	}
+	flags := setFlags(set, nil, map[string]string{"config": "files/a,b.toml"})
+	require.Len(t, flags, 8)
+	assert.Equal(t, []string{
+		"--set", `db.password=p@ss\,w0rd\\`,
+		"--set", `db.url=jdbc:postgresql://db:5432/app?ssl=true\,sslmode=require`,
+		"--set", `tags=\{a\,b}`,
+		"--set-file", `config=/cnab/app/files/a\,b.toml`,
+	}, flags, "the escaped values should stay with their keys, in order")
+}
+
+func TestMixin_WriteStepValues(t *testing.T) {
+	h := NewTestMixin(t)
+
+	valuesPath, cleanup, err := h.writeStepValues(nil)
+	require.NoError(t, err)
+	assert.Empty(t, valuesPath, "no values file should be written without values")
+	cleanup()
+
+	values := map[string]interface{}{
+		"ingress": map[interface{}]interface{}{
+			"enabled": true,
+			"hosts":   []interface{}{"a.example.com", "b.example.com"},
+		},
+		"replicas": 3,
+	}
+	valuesPath, cleanup, err = h.writeStepValues(values)
+	require.NoError(t, err)
+
+	b, err := h.FileSystem.ReadFile(valuesPath)
+	require.NoError(t, err)
+	assert.Equal(t, `ingress:
+  enabled: true
+  hosts:
+  - a.example.com
+  - b.example.com
+replicas: 3
+`, string(b))
+
+	cleanup()
+	exists, err := h.FileSystem.Exists(valuesPath)
+	require.NoError(t, err)
+	assert.False(t, exists, "the values file should be removed")
+}
+
+func TestValuesFlags(t *testing.T) {
+	assert.Equal(t, []string{"--values", "/cnab/app/a.yaml", "--values", "/tmp/helm2-values/values.yaml"},
+		valuesFlags([]string{"/cnab/app/a.yaml"}, "/tmp/helm2-values/values.yaml"),
+		"the step values should override the values files")
+	assert.Empty(t, valuesFlags(nil, ""))
+}
@@ -0,0 +1,162 @@
+package helm2
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+	yaml "gopkg.in/yaml.v2"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// ValuesFrom reads values from a key of a Secret or ConfigMap in the cluster.
+type ValuesFrom struct {
+	Secret    string `yaml:"secret,omitempty"`
+	ConfigMap string `yaml:"configMap,omitempty"`
+
+	// Namespace of the Secret or ConfigMap, defaults to the namespace of the step
+	Namespace string `yaml:"namespace,omitempty"`
+
+	// Key holds a values file, or a single value when TargetPath is set
+	Key string `yaml:"key"`
+
+	// TargetPath is the dotted path, such as mysql.password, that the value of the key is set at
+	TargetPath string `yaml:"targetPath,omitempty"`
+}
+
+// source describes where the values are read from, without their contents.
+func (v ValuesFrom) source(namespace string) string {
+	if v.Secret != "" {
+		return fmt.Sprintf("key %s of secret %s/%s", v.Key, namespace, v.Secret)
+	}
+	return fmt.Sprintf("key %s of configmap %s/%s", v.Key, namespace, v.ConfigMap)
+}
+
+// Validate checks that the values are read from a key of either a Secret or a ConfigMap.
+func (v ValuesFrom) Validate() error {
+	if (v.Secret == "") == (v.ConfigMap == "") {
+		return errors.New("valuesFrom must set either secret or configMap")
+	}
+	if v.Key == "" {
+		return errors.Errorf("valuesFrom %s%s: key must be supplied", v.Secret, v.ConfigMap)
+	}
+	return nil
+}
+
+// getValuesFrom reads the values from the Secrets and ConfigMaps, merged in order.
+// The contents of secrets are never included in the errors, because they are printed.
+func getValuesFrom(client kubernetes.Interface, namespace string, valuesFrom []ValuesFrom) (map[string]interface{}, error) {
+	values := make(map[string]interface{})
+	for _, v := range valuesFrom {
+		err := v.Validate()
+		if err != nil {
+			return nil, err
+		}
+
+		ns := v.Namespace
+		if ns == "" {
+			ns = namespace
+		}
+		if ns == "" {
+			ns = "default"
+		}
+
+		var contents []byte
+		if v.Secret != "" {
+			contents, err = getSecret(client, ns, v.Secret, v.Key)
+		} else {
+			contents, err = getConfigMapValue(client, ns, v.ConfigMap, v.Key)
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "could not read values from %s", v.source(ns))
+		}
+
+		if v.TargetPath != "" {
+			mergeValues(values, valueAtPath(v.TargetPath, string(contents)))
+			continue
+		}
+
+		var file map[string]interface{}
+		err = yaml.Unmarshal(contents, &file)
+		if err != nil {
+			if v.Secret != "" {
+				// The parse error may quote the secret
+				return nil, errors.Errorf("%s is not a YAML values file", v.source(ns))
+			}
+			return nil, errors.Wrapf(err, "%s is not a YAML values file", v.source(ns))
+		}
+		mergeValues(values, file)
+	}
+	return values, nil
+}
+
+func getConfigMapValue(client kubernetes.Interface, namespace, name, key string) ([]byte, error) {
+	configMap, err := client.CoreV1().ConfigMaps(namespace).Get(name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil, fmt.Errorf("error getting configmap %s from namespace %s: %s", name, namespace, err)
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "error getting configmap %s from namespace %s", name, namespace)
+	}
+	if val, ok := configMap.Data[key]; ok {
+		return []byte(val), nil
+	}
+	if val, ok := configMap.BinaryData[key]; ok {
+		return val, nil
+	}
+	return nil, fmt.Errorf("couldn't find key %s in configmap", key)
+}
+
+// valueAtPath returns values with the value set at a dotted path, such as mysql.password.
+func valueAtPath(targetPath string, value interface{}) map[string]interface{} {
+	keys := strings.Split(targetPath, ".")
+	values := map[string]interface{}{keys[len(keys)-1]: value}
+	for i := len(keys) - 2; i >= 0; i-- {
+		values = map[string]interface{}{keys[i]: values}
+	}
+	return values
+}
+
+// mergeValues merges src into dst, replacing the values in dst except for maps,
+// which are merged.
+func mergeValues(dst map[string]interface{}, src map[string]interface{}) {
+	for k, v := range src {
+		v = normalizeValue(v)
+		srcMap, srcIsMap := v.(map[string]interface{})
+		dstMap, dstIsMap := dst[k].(map[string]interface{})
+		if srcIsMap && dstIsMap {
+			mergeValues(dstMap, srcMap)
+			continue
+		}
+		dst[k] = v
+	}
+}
+
+// normalizeValue converts the maps decoded from YAML, which have interface{} keys,
+// to maps with string keys so that they can be merged.
+func normalizeValue(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(t))
+		for k, v := range t {
+			m[fmt.Sprintf("%v", k)] = normalizeValue(v)
+		}
+		return m
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(t))
+		for k, v := range t {
+			m[k] = normalizeValue(v)
+		}
+		return m
+	case []interface{}:
+		l := make([]interface{}, len(t))
+		for i, v := range t {
+			l[i] = normalizeValue(v)
+		}
+		return l
+	default:
+		return v
+	}
+}
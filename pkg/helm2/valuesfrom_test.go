@@ -0,0 +1,135 @@
+package helm2
+
+import (
+	"bytes"
+	"regexp"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	yaml "gopkg.in/yaml.v2"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	testclient "k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestValuesFrom_Validate(t *testing.T) {
+	assert.NoError(t, ValuesFrom{Secret: "db", Key: "password"}.Validate())
+	assert.NoError(t, ValuesFrom{ConfigMap: "env", Key: "values.yaml"}.Validate())
+	assert.EqualError(t, ValuesFrom{Key: "password"}.Validate(), "valuesFrom must set either secret or configMap")
+	assert.EqualError(t, ValuesFrom{Secret: "db", ConfigMap: "env", Key: "password"}.Validate(), "valuesFrom must set either secret or configMap")
+	assert.EqualError(t, ValuesFrom{Secret: "db"}.Validate(), "valuesFrom db: key must be supplied")
+}
+
+func TestGetValuesFrom(t *testing.T) {
+	client := testclient.NewSimpleClientset(
+		&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "env", Namespace: "shared"},
+			Data: map[string]string{"values.yaml": `
+ingress:
+  enabled: true
+  hosts:
+  - app.example.com
+mysql:
+  host: db.example.com
+`},
+		},
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "db", Namespace: "myapp"},
+			Data:       map[string][]byte{"password": []byte("s3cret,p@ss")},
+		},
+	)
+
+	valuesFrom := []ValuesFrom{
+		{ConfigMap: "env", Namespace: "shared", Key: "values.yaml"},
+		{Secret: "db", Key: "password", TargetPath: "mysql.password"},
+	}
+	values, err := getValuesFrom(client, "myapp", valuesFrom)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"ingress": map[string]interface{}{
+			"enabled": true,
+			"hosts":   []interface{}{"app.example.com"},
+		},
+		"mysql": map[string]interface{}{
+			"host":     "db.example.com",
+			"password": "s3cret,p@ss",
+		},
+	}, values, "the values should be merged in order")
+
+	_, err = getValuesFrom(client, "myapp", []ValuesFrom{{Secret: "db", Key: "password"}})
+	require.EqualError(t, err, "key password of secret myapp/db is not a YAML values file")
+	assert.NotContains(t, err.Error(), "s3cret", "the secret should not be printed")
+
+	_, err = getValuesFrom(client, "myapp", []ValuesFrom{{ConfigMap: "env", Key: "missing"}})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "could not read values from key missing of configmap myapp/env")
+}
+
+func TestGetValuesFrom_Forbidden(t *testing.T) {
+	client := testclient.NewSimpleClientset(&corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "db", Namespace: "myapp"},
+		Data:       map[string][]byte{"password": []byte("s3cret")},
+	})
+	client.PrependReactor("get", "secrets", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, apierrors.NewForbidden(schema.GroupResource{Resource: "secrets"}, "db", errors.New("not allowed"))
+	})
+
+	_, err := getValuesFrom(client, "myapp", []ValuesFrom{{Secret: "db", Key: "password"}})
+	require.Error(t, err)
+	assert.True(t, apierrors.IsForbidden(errors.Cause(err)), "the API error should be returned, got %s", err)
+	assert.Contains(t, err.Error(), "could not read values from key password of secret myapp/db: error getting secret db from namespace myapp")
+	assert.NotContains(t, err.Error(), "couldn't find key")
+}
+
+func TestMergeValues(t *testing.T) {
+	var inline map[string]interface{}
+	err := yaml.Unmarshal([]byte("mysql:\n  host: localhost\n  port: 3306\nreplicas: 2\n"), &inline)
+	require.NoError(t, err)
+
+	values := map[string]interface{}{
+		"mysql":    map[string]interface{}{"host": "db.example.com", "password": "s3cret"},
+		"replicas": 1,
+	}
+	mergeValues(values, inline)
+	assert.Equal(t, map[string]interface{}{
+		"mysql":    map[string]interface{}{"host": "localhost", "port": 3306, "password": "s3cret"},
+		"replicas": 2,
+	}, values, "the inline values should override the values from the cluster")
+}
+
+func TestMixin_Install_ValuesFrom(t *testing.T) {
+	h := NewTestMixin(t)
+	_, err := h.KubeClient.CoreV1().Secrets("myapp").Create(&corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "db", Namespace: "myapp"},
+		Data:       map[string][]byte{"password": []byte("s3cret")},
+	})
+	require.NoError(t, err)
+
+	action := InstallAction{Steps: []InstallStep{{InstallArguments: InstallArguments{
+		Step:       Step{Description: "Install MySQL"},
+		Name:       "mysql",
+		Chart:      "stable/mysql",
+		Namespace:  "myapp",
+		ValuesFrom: []ValuesFrom{{Secret: "db", Key: "password", TargetPath: "mysqlRootPassword"}},
+	}}}}
+	b, err := yaml.Marshal(action)
+	require.NoError(t, err)
+	h.In = bytes.NewReader(b)
+
+	err = h.Install()
+	require.NoError(t, err)
+
+	gotOutput := h.TestContext.GetOutput()
+	assert.NotContains(t, gotOutput, "s3cret", "the secret should not be printed")
+	matches := regexp.MustCompile(`helm install --name mysql stable/mysql --namespace myapp --values (\S+)`).FindStringSubmatch(gotOutput)
+	require.Len(t, matches, 2, "the values from the secret should be passed in a values file: %s", gotOutput)
+	exists, err := h.FileSystem.Exists(matches[1])
+	require.NoError(t, err)
+	assert.False(t, exists, "the values file should be removed after the install")
+}
@@ -5,22 +5,34 @@ import (
 
 	"github.com/pkg/errors"
 	k8s "k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
 	// Needed for cluster that require authentication to negotiate a OAuth token
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
 )
 
+// Config describes how to connect to a Kubernetes cluster
+type Config struct {
+	// KubeConfig is the path to the kubeconfig file
+	KubeConfig string
+
+	// Context is the kubeconfig context to use, defaults to the current context
+	Context string
+
+	// InCluster uses the pod's service account instead of a kubeconfig file
+	InCluster bool
+}
+
 // ClientFactory is an interface that knows how to create Kubernetes Clients
 type ClientFactory interface {
-	GetClient(configPath string) (k8s.Interface, error)
+	GetClient(cfg Config) (k8s.Interface, error)
 }
 
 type clientFactory struct {
 }
 
-func (f *clientFactory) GetClient(configPath string) (k8s.Interface, error) {
-
-	config, err := clientcmd.BuildConfigFromFlags("", configPath)
+func (f *clientFactory) GetClient(cfg Config) (k8s.Interface, error) {
+	config, err := buildRestConfig(cfg)
 	if err != nil {
 		return nil, fmt.Errorf("couldn't build kubernetes config: %s", err)
 	}
@@ -31,6 +43,16 @@ func (f *clientFactory) GetClient(configPath string) (k8s.Interface, error) {
 	return clientset, nil
 }
 
+func buildRestConfig(cfg Config) (*rest.Config, error) {
+	if cfg.InCluster {
+		return rest.InClusterConfig()
+	}
+
+	rules := &clientcmd.ClientConfigLoadingRules{ExplicitPath: cfg.KubeConfig}
+	overrides := &clientcmd.ConfigOverrides{CurrentContext: cfg.Context}
+	return clientcmd.NewNonInteractiveDeferredLoadingClientConfig(rules, overrides).ClientConfig()
+}
+
 // New returns an implementation of the ClientFactory interface
 func New() ClientFactory {
 	return &clientFactory{}